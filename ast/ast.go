@@ -3,6 +3,7 @@ package ast
 
 import (
 	"bytes"
+	"math/big"
 	"monkey-interpreter/token"
 	"strconv"
 	"strings"
@@ -11,6 +12,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the first character belonging to the node
+	End() token.Position // position immediately after the last character belonging to the node
 }
 
 // A statement in Monkey does not produce a value
@@ -28,6 +31,7 @@ type Expression interface {
 // Program is the root of the AST which contains all other nodes
 type Program struct {
 	Statements []Statement
+	Comments   []*Comment // every comment in the source, in order. Only populated when the lexer emits comments.
 }
 
 func (p *Program) TokenLiteral() string {
@@ -38,6 +42,20 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out string
 	for _, s := range p.Statements {
@@ -47,17 +65,32 @@ func (p *Program) String() string {
 }
 
 type LetStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
+	Token   token.Token
+	Name    *Identifier
+	Pattern Expression // an array or hash pattern which destructures the value, set instead of Name
+	Value   Expression
 }
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+	if ls.Pattern != nil {
+		return ls.Pattern.End()
+	}
+	return ls.Name.End()
+}
 func (ls *LetStatement) String() string {
 	var out string
 	out += ls.TokenLiteral() + " "
-	out += ls.Name.Value
+	if ls.Pattern != nil {
+		out += ls.Pattern.String()
+	} else {
+		out += ls.Name.Value
+	}
 	out += " = "
 
 	if ls.Value != nil {
@@ -75,6 +108,13 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position {
+	if rs.Value != nil {
+		return rs.Value.End()
+	}
+	return rs.Token.End
+}
 func (rs *ReturnStatement) String() string {
 	var out string
 	out += rs.TokenLiteral() + " "
@@ -88,6 +128,58 @@ func (rs *ReturnStatement) String() string {
 	return out
 }
 
+// Throw statements take the form throw <expression>; and raise the value as an error which can be caught by a try
+// expression
+type ThrowStatement struct {
+	Token token.Token // the 'throw' token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.Position  { return ts.Token.Pos }
+func (ts *ThrowStatement) End() token.Position  { return ts.Value.End() }
+func (ts *ThrowStatement) String() string {
+	return ts.TokenLiteral() + " " + ts.Value.String() + ";"
+}
+
+// While statements take the form while (<condition>) { <statements> }
+type WhileStatement struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) End() token.Position  { return ws.Body.End() }
+func (ws *WhileStatement) String() string {
+	return "while" + ws.Condition.String() + " " + ws.Body.String()
+}
+
+// BreakStatement ends the innermost enclosing loop
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) End() token.Position  { return bs.Token.End }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+// ContinueStatement skips to the next iteration of the innermost enclosing loop
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) End() token.Position  { return cs.Token.End }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
+
 type ExpressionStatement struct {
 	Token token.Token
 	Value Expression
@@ -96,6 +188,13 @@ type ExpressionStatement struct {
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) expressionNode()      {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position {
+	if es.Value != nil {
+		return es.Value.End()
+	}
+	return es.Token.End
+}
 func (es *ExpressionStatement) String() string {
 	if es.Value != nil {
 		return es.Value.String()
@@ -110,6 +209,8 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) End() token.Position  { return i.Token.End }
 func (i *Identifier) String() string {
 	return i.Value
 }
@@ -121,10 +222,35 @@ type IntegerLiteral struct {
 
 func (i *IntegerLiteral) expressionNode()      {}
 func (i *IntegerLiteral) TokenLiteral() string { return i.Token.Literal }
+func (i *IntegerLiteral) Pos() token.Position  { return i.Token.Pos }
+func (i *IntegerLiteral) End() token.Position  { return i.Token.End }
 func (i *IntegerLiteral) String() string {
 	return strconv.FormatInt(i.Value, 10)
 }
 
+// BigIntegerLiteral is an integer literal too large to fit in an int64
+type BigIntegerLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bi *BigIntegerLiteral) expressionNode()      {}
+func (bi *BigIntegerLiteral) TokenLiteral() string { return bi.Token.Literal }
+func (bi *BigIntegerLiteral) Pos() token.Position  { return bi.Token.Pos }
+func (bi *BigIntegerLiteral) End() token.Position  { return bi.Token.End }
+func (bi *BigIntegerLiteral) String() string       { return bi.Value.String() }
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (f *FloatLiteral) expressionNode()      {}
+func (f *FloatLiteral) TokenLiteral() string { return f.Token.Literal }
+func (f *FloatLiteral) Pos() token.Position  { return f.Token.Pos }
+func (f *FloatLiteral) End() token.Position  { return f.Token.End }
+func (f *FloatLiteral) String() string       { return f.Token.Literal }
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -132,10 +258,40 @@ type StringLiteral struct {
 
 func (i *StringLiteral) expressionNode()      {}
 func (i *StringLiteral) TokenLiteral() string { return i.Token.Literal }
+func (i *StringLiteral) Pos() token.Position  { return i.Token.Pos }
+func (i *StringLiteral) End() token.Position  { return i.Token.End }
 func (i *StringLiteral) String() string {
 	return i.Value
 }
 
+// InterpolatedString is a string containing embedded expressions e.g. "total: ${a + b}". Parts holds the literal
+// text as *StringLiteral nodes, alternating with the embedded expressions.
+type InterpolatedString struct {
+	Token token.Token // the STRING_HEAD token
+	Parts []Expression
+	Tail  token.Token // the STRING_TAIL token
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Pos }
+func (is *InterpolatedString) End() token.Position  { return is.Tail.End }
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	for _, part := range is.Parts {
+		if literal, ok := part.(*StringLiteral); ok {
+			out.WriteString(literal.Value)
+		} else {
+			out.WriteString("${")
+			out.WriteString(part.String())
+			out.WriteString("}")
+		}
+	}
+
+	return out.String()
+}
+
 type PrefixExpression struct {
 	Token    token.Token // The prefix token e.g. !
 	Operator string
@@ -144,6 +300,13 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position {
+	if pe.Right != nil {
+		return pe.Right.End()
+	}
+	return pe.Token.End
+}
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -164,6 +327,13 @@ type InfixExpression struct {
 
 func (ie *InfixExpression) expressionNode()      {}
 func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *InfixExpression) End() token.Position {
+	if ie.Right != nil {
+		return ie.Right.End()
+	}
+	return ie.Token.End
+}
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -184,6 +354,18 @@ type Boolean struct {
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
 func (b *Boolean) String() string       { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
+
+type Null struct {
+	Token token.Token
+}
+
+func (n *Null) expressionNode()      {}
+func (n *Null) TokenLiteral() string { return n.Token.Literal }
+func (n *Null) String() string       { return n.Token.Literal }
+func (n *Null) Pos() token.Position  { return n.Token.Pos }
+func (n *Null) End() token.Position  { return n.Token.End }
 
 type IfExpression struct {
 	Token       token.Token // The 'if' token
@@ -194,6 +376,13 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	return ie.Consequence.End()
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -209,13 +398,142 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// For-in expressions take the form for (<value> in <iterable>) { <statements> } or
+// for (<key>, <value> in <iterable>) { <statements> }
+// e.g. for (x in [1, 2]) { puts(x) }
+// e.g. for (k, v in {"a": 1}) { puts(k, v) }
+type ForInExpression struct {
+	Token    token.Token // the 'for' token
+	Key      *Identifier // nil when only the value is bound
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fe *ForInExpression) expressionNode()      {}
+func (fe *ForInExpression) TokenLiteral() string { return fe.Token.Literal }
+func (fe *ForInExpression) Pos() token.Position  { return fe.Token.Pos }
+func (fe *ForInExpression) End() token.Position  { return fe.Body.End() }
+func (fe *ForInExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("for(")
+	if fe.Key != nil {
+		out.WriteString(fe.Key.String() + ", ")
+	}
+	out.WriteString(fe.Value.String() + " in " + fe.Iterable.String() + ") ")
+	out.WriteString(fe.Body.String())
+	return out.String()
+}
+
+// Assign expressions take the form <target> <operator> <expression> where the target is an identifier or an index
+// expression and the operator is one of = += -= *= /=
+// e.g. x = x + 1
+// e.g. arr[0] += 5
+type AssignExpression struct {
+	Token    token.Token // the assignment operator token
+	Target   Expression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return ae.Target.Pos() }
+func (ae *AssignExpression) End() token.Position  { return ae.Value.End() }
+func (ae *AssignExpression) String() string {
+	return "(" + ae.Target.String() + " " + ae.Operator + " " + ae.Value.String() + ")"
+}
+
+// Conditional expressions take the form <condition> ? <consequence> : <alternative>
+// e.g. x < 0 ? -x : x
+type ConditionalExpression struct {
+	Token       token.Token // the '?' token
+	Condition   Expression
+	Consequence Expression
+	Alternative Expression
+}
+
+func (ce *ConditionalExpression) expressionNode()      {}
+func (ce *ConditionalExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *ConditionalExpression) Pos() token.Position  { return ce.Condition.Pos() }
+func (ce *ConditionalExpression) End() token.Position  { return ce.Alternative.End() }
+func (ce *ConditionalExpression) String() string {
+	return "(" + ce.Condition.String() + " ? " + ce.Consequence.String() + " : " + ce.Alternative.String() + ")"
+}
+
+// Try expressions take the form try { <statements> } catch (<identifier>) { <statements> } finally { <statements> }
+// where either the catch or the finally clause may be left out
+type TryExpression struct {
+	Token   token.Token // the 'try' token
+	Body    *BlockStatement
+	Param   *Identifier // bound to the caught error, nil without a catch clause
+	Catch   *BlockStatement
+	Finally *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) Pos() token.Position  { return te.Token.Pos }
+func (te *TryExpression) End() token.Position {
+	if te.Finally != nil {
+		return te.Finally.End()
+	}
+	return te.Catch.End()
+}
+func (te *TryExpression) String() string {
+	out := "try " + te.Body.String()
+	if te.Catch != nil {
+		out += " catch(" + te.Param.String() + ") " + te.Catch.String()
+	}
+	if te.Finally != nil {
+		out += " finally " + te.Finally.String()
+	}
+	return out
+}
+
+// Match expressions take the form match (<expression>) { <pattern> [if <guard>] => <expression>, ... } and evaluate the
+// expression of the first arm whose pattern matches the value and whose guard, if any, is truthy. Patterns are the
+// same as those of a destructuring let statement e.g. 0, [first, ...rest] or {name}
+type MatchExpression struct {
+	Token   token.Token // the 'match' token
+	Subject Expression
+	Arms    []*MatchArm
+	Rbrace  token.Token // the '}' token
+}
+
+type MatchArm struct {
+	Pattern Expression
+	Guard   Expression // nil when the arm has no guard
+	Body    Expression
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MatchExpression) End() token.Position  { return me.Rbrace.End }
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		s := arm.Pattern.String()
+		if arm.Guard != nil {
+			s += " if " + arm.Guard.String()
+		}
+		arms = append(arms, s+" => "+arm.Body.String())
+	}
+
+	return "match(" + me.Subject.String() + ") { " + strings.Join(arms, ", ") + " }"
+}
+
 type BlockStatement struct {
 	Token      token.Token // the { token}
 	Statements []Statement
+	Rbrace     token.Token // the } token
 }
 
 func (bs *BlockStatement) expressionNode()      {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position  { return bs.Rbrace.End }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -229,20 +547,34 @@ func (bs *BlockStatement) String() string {
 type FunctionLiteral struct {
 	Token      token.Token // the 'fn' token
 	Parameters []*Identifier
+	Defaults   map[string]Expression // default values keyed by parameter name e.g. fn(a, b = 10)
+	Rest       *Identifier           // the rest parameter collecting any remaining arguments e.g. fn(a, ...rest)
 	Body       *BlockStatement
+	Name       string // the name the function is bound to by a let statement, empty for anonymous functions
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position  { return fl.Body.End() }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("fn(")
 	for i, p := range fl.Parameters {
-		out.WriteString((p.Value))
 		if i > 0 {
 			out.WriteString(", ")
 		}
+		out.WriteString(p.Value)
+		if value, ok := fl.Defaults[p.Value]; ok {
+			out.WriteString(" = " + value.String())
+		}
+	}
+	if fl.Rest != nil {
+		if len(fl.Parameters) > 0 {
+			out.WriteString(", ")
+		}
+		out.WriteString("..." + fl.Rest.Value)
 	}
 	out.WriteString(")")
 	out.WriteString("{ ")
@@ -259,10 +591,13 @@ type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or function literal
 	Arguments []Expression
+	Rparen    token.Token // the ')' token
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return ce.Function.Pos() }
+func (ce *CallExpression) End() token.Position  { return ce.Rparen.End }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -279,13 +614,31 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// Spread expressions take the form ...<expression> and expand an array into the surrounding argument list or array
+// literal
+// e.g. f(...args)
+// e.g. [...a, ...b]
+type SpreadExpression struct {
+	Token token.Token // the '...' token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SpreadExpression) End() token.Position  { return se.Value.End() }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
+	Rbracket token.Token // the ']' token
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position  { return al.Rbracket.End }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -301,18 +654,25 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // the '[' token
-	Left  Expression
-	Index Expression
+	Token    token.Token // the '[' token
+	Left     Expression
+	Index    Expression
+	Optional bool        // written left?.[index], which is null rather than an error when left is null
+	Rbracket token.Token // the ']' token
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Left.Pos() }
+func (ie *IndexExpression) End() token.Position  { return ie.Rbracket.End }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("(")
 	out.WriteString(ie.Left.String())
+	if ie.Optional {
+		out.WriteString("?.")
+	}
 	out.WriteString("[")
 	out.WriteString(ie.Index.String())
 	out.WriteString("])")
@@ -321,12 +681,15 @@ func (ie *IndexExpression) String() string {
 }
 
 type HashLiteral struct {
-	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Token  token.Token // the '{' token
+	Pairs  map[Expression]Expression
+	Rbrace token.Token // the '}' token
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position  { return hl.Rbrace.End }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
@@ -340,3 +703,67 @@ func (hl *HashLiteral) String() string {
 
 	return out.String()
 }
+
+// HashPattern destructures a hash in a let statement or match arm e.g. {name, "years": age}. The value of each key
+// is destructured by the pattern at the same index of Values, and an identifier written alone is shorthand for the
+// key of the same name bound to that identifier.
+type HashPattern struct {
+	Token  token.Token  // the '{' token
+	Keys   []Expression // string, integer or boolean literals
+	Values []Expression
+	Rbrace token.Token // the '}' token
+}
+
+func (hp *HashPattern) expressionNode()      {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) Pos() token.Position  { return hp.Token.Pos }
+func (hp *HashPattern) End() token.Position  { return hp.Rbrace.End }
+func (hp *HashPattern) String() string {
+	entries := []string{}
+	for i, key := range hp.Keys {
+		if ident, ok := hp.Values[i].(*Identifier); ok && ident.Token.Pos == key.Pos() {
+			entries = append(entries, ident.Value)
+		} else {
+			entries = append(entries, key.String()+": "+hp.Values[i].String())
+		}
+	}
+
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+// BadStatement is a placeholder for a statement containing syntax errors. It allows the rest of the program to be
+// parsed into a usable tree.
+type BadStatement struct {
+	Token token.Token // the first token of the malformed statement
+	Last  token.Token // the last token of the malformed statement
+}
+
+func (bs *BadStatement) statementNode()       {}
+func (bs *BadStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BadStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BadStatement) End() token.Position  { return bs.Last.End }
+func (bs *BadStatement) String() string       { return "<bad statement>" }
+
+// BadExpression is a placeholder for an expression containing syntax errors. It allows the rest of the program to be
+// parsed into a usable tree.
+type BadExpression struct {
+	Token token.Token // the first token of the malformed expression
+	Last  token.Token // the last token of the malformed expression
+}
+
+func (be *BadExpression) expressionNode()      {}
+func (be *BadExpression) TokenLiteral() string { return be.Token.Literal }
+func (be *BadExpression) Pos() token.Position  { return be.Token.Pos }
+func (be *BadExpression) End() token.Position  { return be.Last.End }
+func (be *BadExpression) String() string       { return "<bad expression>" }
+
+// Comment is a // line comment or /* block comment */. Comments are not statements, they are collected on the
+// Program so that tools can relate them to the surrounding nodes by position.
+type Comment struct {
+	Token token.Token // the COMMENT token
+}
+
+func (c *Comment) TokenLiteral() string { return c.Token.Literal }
+func (c *Comment) String() string       { return c.Token.Literal }
+func (c *Comment) Pos() token.Position  { return c.Token.Pos }
+func (c *Comment) End() token.Position  { return c.Token.End }
@@ -136,6 +136,11 @@ func TestFunctionLiteralString(t *testing.T) {
 	if ce.String() != "fn(foo){ bar }" {
 		t.Fatalf("string not correct. expected=fn(foo){ bar }, got=%s", ce.String())
 	}
+
+	ce.Parameters = append(ce.Parameters, &Identifier{Token: token.Token{Type: token.IDENT, Literal: "baz"}, Value: "baz"})
+	if ce.String() != "fn(foo, baz){ bar }" {
+		t.Fatalf("string not correct. expected=fn(foo, baz){ bar }, got=%s", ce.String())
+	}
 }
 
 func TestArrayLiteralString(t *testing.T) {
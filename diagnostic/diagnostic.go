@@ -0,0 +1,96 @@
+// Package diagnostic describes problems found in monkey source code and renders them for humans to read.
+package diagnostic
+
+import (
+	"fmt"
+	"io"
+	"monkey-interpreter/token"
+	"strings"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Note
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	case Note:
+		return "note"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+// Diagnostic is a single problem found in monkey source code, along with the span of source it applies to.
+type Diagnostic struct {
+	Severity Severity
+	Message  string
+	Pos      token.Position    // start of the offending source
+	End      token.Position    // end of the offending source
+	Expected []token.TokenType // token types which would have been valid here, if known
+	Found    token.Token       // the token which was found instead, if known
+	Hint     string            // optional suggestion for fixing the problem
+}
+
+// String formats the diagnostic on a single line e.g. 1:5: error: unexpected token
+func (d Diagnostic) String() string {
+	if !d.Pos.IsValid() && d.Pos.Filename == "" {
+		return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, d.Message)
+}
+
+// Render writes the diagnostic to out followed by the offending line of source with the span underlined, e.g.
+//
+//	1:7: error: expected next token to be =, got INT instead
+//	let x 5;
+//	      ^
+func Render(out io.Writer, source string, d Diagnostic) {
+	io.WriteString(out, d.String()+"\n")
+
+	lines := strings.Split(source, "\n")
+	if d.Pos.IsValid() && d.Pos.Line <= len(lines) {
+		line := strings.TrimRight(lines[d.Pos.Line-1], "\r")
+		io.WriteString(out, line+"\n")
+		io.WriteString(out, underline(line, d.Pos, d.End)+"\n")
+	}
+
+	if d.Hint != "" {
+		io.WriteString(out, "hint: "+d.Hint+"\n")
+	}
+}
+
+// underline returns a line of carets positioned beneath the span from start to end. Columns are counted in
+// characters, and tabs in the source line are preserved so that the carets line up however the terminal renders them.
+func underline(line string, start, end token.Position) string {
+	var out strings.Builder
+
+	column := 1
+	for _, ch := range line {
+		if column >= start.Column {
+			break
+		}
+
+		if ch == '\t' {
+			out.WriteRune('\t')
+		} else {
+			out.WriteRune(' ')
+		}
+		column++
+	}
+
+	width := 1
+	if end.Line == start.Line && end.Column > start.Column {
+		width = end.Column - start.Column
+	}
+	out.WriteString(strings.Repeat("^", width))
+
+	return out.String()
+}
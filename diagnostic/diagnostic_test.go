@@ -0,0 +1,77 @@
+package diagnostic
+
+import (
+	"bytes"
+	"monkey-interpreter/token"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		diagnostic Diagnostic
+		expected   string
+	}{
+		{
+			Diagnostic{Severity: Error, Message: "boom", Pos: token.Position{Line: 2, Column: 3}},
+			"2:3: error: boom",
+		},
+		{
+			Diagnostic{Severity: Warning, Message: "boom", Pos: token.Position{Filename: "a.mk", Line: 1, Column: 1}},
+			"a.mk:1:1: warning: boom",
+		},
+		{
+			Diagnostic{Severity: Note, Message: "boom"},
+			"note: boom",
+		},
+	}
+
+	for i, tt := range tests {
+		if tt.diagnostic.String() != tt.expected {
+			t.Errorf("tests[%d] - string wrong. expected=%q, got=%q", i, tt.expected, tt.diagnostic.String())
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	source := "let a = 1;\n\tlet b 22;"
+	d := Diagnostic{
+		Severity: Error,
+		Message:  "expected next token to be =, got INT instead",
+		Pos:      token.Position{Offset: 18, Line: 2, Column: 8},
+		End:      token.Position{Offset: 20, Line: 2, Column: 10},
+		Hint:     "add an = after the name",
+	}
+
+	var out bytes.Buffer
+	Render(&out, source, d)
+
+	expected := "2:8: error: expected next token to be =, got INT instead\n" +
+		"\tlet b 22;\n" +
+		"\t      ^^\n" +
+		"hint: add an = after the name\n"
+
+	if out.String() != expected {
+		t.Errorf("rendered diagnostic wrong. expected=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestRenderUnicode(t *testing.T) {
+	source := `let größe = "🙈" +;`
+	d := Diagnostic{
+		Severity: Error,
+		Message:  "no prefix parse function for ; found.",
+		Pos:      token.Position{Line: 1, Column: 19},
+		End:      token.Position{Line: 1, Column: 20},
+	}
+
+	var out bytes.Buffer
+	Render(&out, source, d)
+
+	expected := "1:19: error: no prefix parse function for ; found.\n" +
+		"let größe = \"🙈\" +;\n" +
+		"                  ^\n"
+
+	if out.String() != expected {
+		t.Errorf("rendered diagnostic wrong. expected=%q, got=%q", expected, out.String())
+	}
+}
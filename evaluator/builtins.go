@@ -1,6 +1,13 @@
 package evaluator
 
-import "monkey-interpreter/object"
+import (
+	"math"
+	"math/big"
+	"monkey-interpreter/object"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
 
 var builtins = map[string]*object.BuiltIn{
 	"len": {
@@ -11,7 +18,7 @@ var builtins = map[string]*object.BuiltIn{
 
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			default:
@@ -19,6 +26,19 @@ var builtins = map[string]*object.BuiltIn{
 			}
 		},
 	},
+	"byte_len": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != object.STRING_OBJ {
+				return newError("argument to `byte_len` must be STRING, got %s", args[0].Type())
+			}
+
+			return &object.Integer{Value: int64(len(args[0].(*object.String).Value))}
+		},
+	},
 	"first": {
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
@@ -96,6 +116,125 @@ var builtins = map[string]*object.BuiltIn{
 			return &object.Array{Elements: newElements}
 		},
 	},
+	"float": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInt:
+				f, _ := toFloat(arg)
+				return &object.Float{Value: f}
+			case *object.Float:
+				return arg
+			case *object.String:
+				f, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError("could not convert %q to FLOAT", arg.Value)
+				}
+				return &object.Float{Value: f}
+			default:
+				return newError("argument to `float` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"int": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *object.Integer, *object.BigInt:
+				return arg
+			case *object.Float:
+				if math.IsNaN(arg.Value) || math.IsInf(arg.Value, 0) {
+					return newError("could not convert %s to INTEGER", arg.Inspect())
+				}
+				// truncates toward zero
+				i, _ := big.NewFloat(arg.Value).Int(nil)
+				return object.IntegerFromBig(i)
+			case *object.String:
+				i, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 10)
+				if !ok {
+					return newError("could not convert %q to INTEGER", arg.Value)
+				}
+				return object.IntegerFromBig(i)
+			default:
+				return newError("argument to `int` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"round": {
+		Fn: func(args ...object.Object) object.Object {
+			return roundNumber("round", math.Round, args)
+		},
+	},
+	"floor": {
+		Fn: func(args ...object.Object) object.Object {
+			return roundNumber("floor", math.Floor, args)
+		},
+	},
+	"sqrt": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			f, ok := toFloat(args[0])
+			if !ok {
+				return newError("argument to `sqrt` must be INTEGER or FLOAT, got %s", args[0].Type())
+			}
+			if f < 0 {
+				return newError("argument to `sqrt` must not be negative, got %s", args[0].Inspect())
+			}
+
+			return &object.Float{Value: math.Sqrt(f)}
+		},
+	},
+	"format_int": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if !isInteger(args[0]) {
+				return newError("first argument to `format_int` must be INTEGER, got %s", args[0].Type())
+			}
+
+			base, ok := args[1].(*object.Integer)
+			if !ok {
+				return newError("second argument to `format_int` must be INTEGER, got %s", args[1].Type())
+			}
+			if base.Value < 2 || base.Value > 36 {
+				return newError("base for `format_int` must be between 2 and 36, got %d", base.Value)
+			}
+
+			if n, ok := args[0].(*object.BigInt); ok {
+				return &object.String{Value: n.InspectBase(int(base.Value))}
+			}
+			return &object.String{Value: args[0].(*object.Integer).InspectBase(int(base.Value))}
+		},
+	},
+	"error": {
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 && len(args) != 2 {
+				return newError("wrong number of arguments. got=%d, want=1 or 2", len(args))
+			}
+
+			message, ok := args[0].(*object.String)
+			if !ok {
+				return newError("first argument to `error` must be STRING, got %s", args[0].Type())
+			}
+
+			err := &object.Error{Message: message.Value, Kind: object.USER_ERROR, Data: NULL}
+			if len(args) == 2 {
+				err.Data = args[1]
+			}
+			return &object.ErrorValue{Error: err}
+		},
+	},
 	"puts": {
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
@@ -105,3 +244,19 @@ var builtins = map[string]*object.BuiltIn{
 		},
 	},
 }
+
+// roundNumber applies fn to a float argument, integers are already whole so they are returned unchanged
+func roundNumber(name string, fn func(float64) float64, args []object.Object) object.Object {
+	if len(args) != 1 {
+		return newError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	switch arg := args[0].(type) {
+	case *object.Integer, *object.BigInt:
+		return arg
+	case *object.Float:
+		return &object.Float{Value: fn(arg.Value)}
+	default:
+		return newError("argument to `%s` must be INTEGER or FLOAT, got %s", name, args[0].Type())
+	}
+}
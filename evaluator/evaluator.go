@@ -2,100 +2,201 @@
 package evaluator
 
 import (
+	"bytes"
 	"fmt"
+	"math"
+	"math/big"
 	"monkey-interpreter/ast"
 	"monkey-interpreter/object"
 	"monkey-interpreter/token"
+	"strings"
 )
 
 var (
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
 	NULL  = &object.Null{}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
-func Eval(node ast.Node, environment *object.Environment) object.Object {
+// maxCallDepth is the number of function calls which may be evaluated inside each other before evaluation stops with
+// an error, so that runaway recursion cannot exhaust the Go stack and crash the host process
+const maxCallDepth = 10000
+
+// callDepth is the number of function calls currently being evaluated
+var callDepth int
+
+// Eval evaluates node in the given environment. Runtime errors are returned as *object.Error values positioned at the
+// source which raised them, and a Go panic while evaluating is recovered and returned as an internal error.
+func Eval(node ast.Node, environment *object.Environment) (result object.Object) {
+	defer func() {
+		if r := recover(); r != nil {
+			err := newError("internal error: %v", r)
+			err.Fatal = true
+			result = err
+		}
+	}()
+
+	return eval(node, environment)
+}
+
+// eval evaluates node, giving any error raised by node which does not yet have a position the position of node
+func eval(node ast.Node, environment *object.Environment) object.Object {
+	result := evalNode(node, environment)
+	setErrorPosition(result, node)
+
+	return result
+}
+
+// setErrorPosition gives result the position of node if it is an error which does not yet have a position
+func setErrorPosition(result object.Object, node ast.Node) {
+	if errObj, ok := result.(*object.Error); ok && !errObj.Pos.IsValid() && node != nil {
+		errObj.Pos = node.Pos()
+		errObj.End = node.End()
+	}
+}
+
+func evalNode(node ast.Node, environment *object.Environment) object.Object {
 	switch node := node.(type) {
 	// statements
 	case *ast.Program:
 		return evalProgram(node.Statements, environment)
 	case *ast.ExpressionStatement:
-		return Eval(node.Value, environment)
+		return eval(node.Value, environment)
 	case *ast.BlockStatement:
 		return evalBlockStatement(node.Statements, environment)
 	case *ast.ReturnStatement:
-		return &object.ReturnValue{Value: Eval(node.Value, environment)}
+		value := eval(node.Value, environment)
+		if isSignal(value) {
+			return value
+		}
+		return &object.ReturnValue{Value: value}
+	case *ast.ThrowStatement:
+		return evalThrowStatement(node, environment)
 	case *ast.LetStatement:
 		return evalLetStatement(node, environment)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, environment)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 
 	// expressions
+	case *ast.BigIntegerLiteral:
+		return &object.BigInt{Value: new(big.Int).Set(node.Value)}
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+	case *ast.Null:
+		return NULL
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.StringLiteral:
 		return nativeStringToStringObject(node.Value)
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, environment)
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, environment)
-		if isError(right) {
+		right := eval(node.Right, environment)
+		if isSignal(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 	case *ast.InfixExpression:
-		left := Eval(node.Left, environment)
-		if isError(left) {
+		left := eval(node.Left, environment)
+		if isSignal(left) {
 			return left
 		}
-		right := Eval(node.Right, environment)
-		if isError(right) {
+		if node.Operator == token.AND || node.Operator == token.OR {
+			return evalLogicalExpression(node.Operator, left, node.Right, environment)
+		}
+		// the right operand of ?? is only evaluated when the left is null
+		if node.Operator == token.NULLISH {
+			if left != NULL {
+				return left
+			}
+			return eval(node.Right, environment)
+		}
+		right := eval(node.Right, environment)
+		if isSignal(right) {
 			return right
 		}
 		return evalInfixExperession(node.Operator, left, right)
 	case *ast.IfExpression:
-		condition := Eval(node.Condition, environment)
-
-		if condition == nil {
-			return NULL
+		condition := eval(node.Condition, environment)
+		if isSignal(condition) {
+			return condition
 		}
 
-		boolCondition, ok := condition.(*object.Boolean)
-
-		// conditions which contain a value (not nil) which is not a bool are truthy
-		if !ok || boolCondition.Value {
-			return Eval(node.Consequence, environment)
+		if isTruthy(condition) {
+			return eval(node.Consequence, environment)
 		} else {
 			if node.Alternative == nil {
 				return NULL
 			}
-			return Eval(node.Alternative, environment)
+			return eval(node.Alternative, environment)
 		}
-	case *ast.IndexExpression:
-		left := Eval(node.Left, environment)
-		if isError(left) {
-			return left
+	case *ast.ConditionalExpression:
+		condition := eval(node.Condition, environment)
+		if isSignal(condition) {
+			return condition
 		}
-		index := Eval(node.Index, environment)
-		if isError(index) {
-			return index
+
+		if isTruthy(condition) {
+			return eval(node.Consequence, environment)
 		}
-		return evalIndexExpression(left, index)
+		return eval(node.Alternative, environment)
+	case *ast.TryExpression:
+		return evalTryExpression(node, environment)
+	case *ast.ForInExpression:
+		return evalForInExpression(node, environment)
+	case *ast.MatchExpression:
+		return evalMatchExpression(node, environment)
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, environment)
+	case *ast.IndexExpression:
+		result, _ := evalIndexChain(node, environment)
+		return result
 	case *ast.Identifier:
 		return evalIdentifier(node.Value, environment)
 	case *ast.FunctionLiteral:
-		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: environment}
+		return &object.Function{
+			Parameters: node.Parameters,
+			Defaults:   node.Defaults,
+			Rest:       node.Rest,
+			Body:       node.Body,
+			Env:        environment,
+			Name:       node.Name,
+		}
 	case *ast.CallExpression:
 		return evalCallStatement(node, environment)
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, environment)
-		if len(elements) == 1 && isError(elements[0]) {
+		if len(elements) == 1 && isSignal(elements[0]) {
 			return elements[0]
 		}
 		return &object.Array{Elements: elements}
 	case *ast.HashLiteral:
 		return evalHashLiteral(node, environment)
+	case *ast.SpreadExpression:
+		// only arrays can be spread, the elements are expanded into the surrounding list by evalExpressions
+		value := eval(node.Value, environment)
+		if isSignal(value) || value.Type() == object.ARRAY_OBJ {
+			return value
+		}
+		return newError("cannot spread %s, expected ARRAY", value.Type())
+
+	// placeholders for source which failed to parse
+	case *ast.BadStatement, *ast.BadExpression:
+		return newError("cannot evaluate malformed source at %s", node.Pos())
 	}
 
-	return nil
+	err := newError("cannot evaluate node of type %T", node)
+	err.Fatal = true
+	return err
 }
 
 func evalInfixExperession(operator string, left, right object.Object) object.Object {
@@ -103,6 +204,15 @@ func evalInfixExperession(operator string, left, right object.Object) object.Obj
 		return evalIntegerInfixExpression(operator, left, right)
 	}
 
+	if isInteger(left) && isInteger(right) {
+		return evalBigIntInfixExpression(operator, left, right)
+	}
+
+	// mixing an integer with a float promotes the integer to a float
+	if isNumeric(left) && isNumeric(right) {
+		return evalFloatInfixExpression(operator, left, right)
+	}
+
 	if left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ {
 		return evalBooleanInfixExpression(operator, left, right)
 	}
@@ -111,6 +221,30 @@ func evalInfixExperession(operator string, left, right object.Object) object.Obj
 		return evalStringInfixExpression(operator, left, right)
 	}
 
+	if left.Type() == object.ARRAY_OBJ && right.Type() == object.ARRAY_OBJ {
+		return evalArrayInfixExpression(operator, left, right)
+	}
+
+	if left.Type() == object.HASH_OBJ && right.Type() == object.HASH_OBJ {
+		return evalHashInfixExpression(operator, left, right)
+	}
+
+	// error values are equal when they hold the same error, such as an error value and the error caught by throwing it
+	if left.Type() == object.ERROR_VALUE_OBJ && right.Type() == object.ERROR_VALUE_OBJ {
+		same := left.(*object.ErrorValue).Error == right.(*object.ErrorValue).Error
+		switch operator {
+		case token.EQ:
+			return nativeBoolToBooleanObject(same)
+		case token.NOT_EQ:
+			return nativeBoolToBooleanObject(!same)
+		}
+	}
+
+	// any value can be compared with null
+	if (left == NULL || right == NULL) && (operator == token.EQ || operator == token.NOT_EQ) {
+		return nativeBoolToBooleanObject((left == right) == (operator == token.EQ))
+	}
+
 	if left.Type() != right.Type() {
 		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	}
@@ -119,14 +253,136 @@ func evalInfixExperession(operator string, left, right object.Object) object.Obj
 }
 
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	// strings are ordered lexicographically by byte, which for utf-8 is the same as ordering by code point
 	switch operator {
 	case token.PLUS:
-		return &object.String{Value: left.(*object.String).Value + right.(*object.String).Value}
+		return &object.String{Value: leftVal + rightVal}
+	case token.LT:
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case token.GT:
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case token.LT_EQ:
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case token.GT_EQ:
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case token.EQ:
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case token.NOT_EQ:
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	}
+
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func evalArrayInfixExpression(operator string, left, right object.Object) object.Object {
+	switch operator {
+	case token.EQ:
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
+	case token.NOT_EQ:
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
+	case token.LT, token.GT, token.LT_EQ, token.GT_EQ:
+		return compareArrays(operator, left.(*object.Array), right.(*object.Array), map[[2]object.Object]bool{})
+	}
+
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+// compareArrays orders arrays lexicographically, the first pair of elements which are not equal decides the order
+// and otherwise the shorter array comes first. seen holds the pairs of arrays already being compared, a pair which
+// is reached again through arrays containing themselves cannot decide the order and is skipped
+func compareArrays(operator string, left, right *object.Array, seen map[[2]object.Object]bool) object.Object {
+	seen[[2]object.Object{left, right}] = true
+
+	for i := 0; i < len(left.Elements) && i < len(right.Elements); i++ {
+		l, r := left.Elements[i], right.Elements[i]
+		if objectsEqual(l, r) {
+			continue
+		}
+
+		leftArray, leftOk := l.(*object.Array)
+		rightArray, rightOk := r.(*object.Array)
+		if !leftOk || !rightOk {
+			return evalInfixExperession(operator, l, r)
+		}
+		if !seen[[2]object.Object{leftArray, rightArray}] {
+			return compareArrays(operator, leftArray, rightArray, seen)
+		}
+	}
+
+	leftLen := &object.Integer{Value: int64(len(left.Elements))}
+	rightLen := &object.Integer{Value: int64(len(right.Elements))}
+	return evalIntegerInfixExpression(operator, leftLen, rightLen)
+}
+
+func evalHashInfixExpression(operator string, left, right object.Object) object.Object {
+	switch operator {
+	case token.EQ:
+		return nativeBoolToBooleanObject(objectsEqual(left, right))
+	case token.NOT_EQ:
+		return nativeBoolToBooleanObject(!objectsEqual(left, right))
 	}
 
 	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 }
 
+// objectsEqual reports whether two objects are equal. Numbers are compared by value, strings by content, arrays and
+// hashes by comparing their elements, and any other object by identity
+func objectsEqual(left, right object.Object) bool {
+	return equal(left, right, map[[2]object.Object]bool{})
+}
+
+// equal implements objectsEqual. seen holds the pairs of arrays and hashes already being compared, which are assumed
+// to be equal when they are reached again so that comparing values which contain themselves terminates
+func equal(left, right object.Object, seen map[[2]object.Object]bool) bool {
+	if isNumeric(left) && isNumeric(right) {
+		return evalInfixExperession(token.EQ, left, right) == TRUE
+	}
+
+	pair := [2]object.Object{left, right}
+
+	switch left := left.(type) {
+	case *object.String:
+		right, ok := right.(*object.String)
+		return ok && left.Value == right.Value
+	case *object.Array:
+		right, ok := right.(*object.Array)
+		if !ok || len(left.Elements) != len(right.Elements) {
+			return false
+		}
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+		for i := range left.Elements {
+			if !equal(left.Elements[i], right.Elements[i], seen) {
+				return false
+			}
+		}
+		return true
+	case *object.Hash:
+		right, ok := right.(*object.Hash)
+		if !ok || len(left.Pairs) != len(right.Pairs) {
+			return false
+		}
+		if seen[pair] {
+			return true
+		}
+		seen[pair] = true
+		for key, leftPair := range left.Pairs {
+			rightPair, ok := right.Pairs[key]
+			if !ok || !equal(leftPair.Value, rightPair.Value, seen) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return left == right
+}
+
 func evalBooleanInfixExpression(operator string, left, right object.Object) object.Object {
 	// no need to unwrap value here, can rely on pointer comparison as nativeBoolToBooleanObject implementation
 	// ensures that boolean objects are pointers to same singleton memory address. this is faster,
@@ -142,23 +398,267 @@ func evalBooleanInfixExpression(operator string, left, right object.Object) obje
 	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 }
 
+// evalLogicalExpression evaluates && and ||, only evaluating the right operand when the left operand does not already
+// decide the result
+func evalLogicalExpression(operator string, left object.Object, right ast.Expression, environment *object.Environment) object.Object {
+	if operator == token.AND && !isTruthy(left) {
+		return FALSE
+	}
+	if operator == token.OR && isTruthy(left) {
+		return TRUE
+	}
+
+	result := eval(right, environment)
+	if isSignal(result) {
+		return result
+	}
+	return nativeBoolToBooleanObject(isTruthy(result))
+}
+
+// isTruthy reports whether obj counts as true in a condition. Only false and null are falsy, any other value is
+// truthy, including 0 and the empty string
+func isTruthy(obj object.Object) bool {
+	if obj == NULL {
+		return false
+	}
+
+	boolean, ok := obj.(*object.Boolean)
+	return !ok || boolean.Value
+}
+
 func evalIntegerInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	leftVal := left.(*object.Integer).Value
 	rightVal := right.(*object.Integer).Value
 
+	// operations which overflow an int64 are redone with arbitrary precision
 	switch operator {
 	case token.PLUS:
-		return &object.Integer{Value: leftVal + rightVal}
+		sum := leftVal + rightVal
+		if (rightVal > 0 && sum < leftVal) || (rightVal < 0 && sum > leftVal) {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: sum}
 	case token.MINUS:
-		return &object.Integer{Value: leftVal - rightVal}
+		difference := leftVal - rightVal
+		if (rightVal > 0 && difference > leftVal) || (rightVal < 0 && difference < leftVal) {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: difference}
 	case token.ASTERISK:
-		return &object.Integer{Value: leftVal * rightVal}
+		product := leftVal * rightVal
+		if leftVal != 0 && (product/leftVal != rightVal || leftVal == -1 && rightVal == math.MinInt64) {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
+		return &object.Integer{Value: product}
 	case token.SLASH:
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		if leftVal == math.MinInt64 && rightVal == -1 {
+			return evalBigIntInfixExpression(operator, left, right)
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case token.PERCENT:
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
+	case token.POWER:
+		return evalBigIntInfixExpression(operator, left, right)
+	case token.AMPERSAND:
+		return &object.Integer{Value: leftVal & rightVal}
+	case token.PIPE:
+		return &object.Integer{Value: leftVal | rightVal}
+	case token.CARET:
+		return &object.Integer{Value: leftVal ^ rightVal}
+	case token.SHIFT_LEFT:
+		if rightVal >= 0 && rightVal < 63 && (leftVal<<rightVal)>>rightVal == leftVal {
+			return &object.Integer{Value: leftVal << rightVal}
+		}
+		return evalBigIntInfixExpression(operator, left, right)
+	case token.SHIFT_RIGHT:
+		if rightVal < 0 {
+			return newError("negative shift count: %d", rightVal)
+		}
+		return &object.Integer{Value: leftVal >> rightVal}
+	case token.LT:
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case token.GT:
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case token.LT_EQ:
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case token.GT_EQ:
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case token.EQ:
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case token.NOT_EQ:
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	}
+
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func evalInterpolatedString(node *ast.InterpolatedString, environment *object.Environment) object.Object {
+	var out bytes.Buffer
+
+	for _, part := range node.Parts {
+		if literal, ok := part.(*ast.StringLiteral); ok {
+			out.WriteString(literal.Value)
+			continue
+		}
+
+		value := eval(part, environment)
+		if isSignal(value) {
+			return value
+		}
+		out.WriteString(value.Inspect())
+	}
+
+	return nativeStringToStringObject(out.String())
+}
+
+func evalBigIntInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toBigInt(left)
+	rightVal := toBigInt(right)
+
+	switch operator {
+	case token.PLUS:
+		return object.IntegerFromBig(new(big.Int).Add(leftVal, rightVal))
+	case token.MINUS:
+		return object.IntegerFromBig(new(big.Int).Sub(leftVal, rightVal))
+	case token.ASTERISK:
+		return object.IntegerFromBig(new(big.Int).Mul(leftVal, rightVal))
+	case token.SLASH:
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		// Quo truncates toward zero the same as int64 division
+		return object.IntegerFromBig(new(big.Int).Quo(leftVal, rightVal))
+	case token.PERCENT:
+		if rightVal.Sign() == 0 {
+			return newError("division by zero")
+		}
+		// Rem takes the sign of the dividend the same as int64 modulo
+		return object.IntegerFromBig(new(big.Int).Rem(leftVal, rightVal))
+	case token.POWER:
+		return evalIntegerPower(leftVal, rightVal)
+	case token.AMPERSAND:
+		return object.IntegerFromBig(new(big.Int).And(leftVal, rightVal))
+	case token.PIPE:
+		return object.IntegerFromBig(new(big.Int).Or(leftVal, rightVal))
+	case token.CARET:
+		return object.IntegerFromBig(new(big.Int).Xor(leftVal, rightVal))
+	case token.SHIFT_LEFT, token.SHIFT_RIGHT:
+		return evalIntegerShift(operator, leftVal, rightVal)
+	case token.LT:
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) < 0)
+	case token.GT:
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) > 0)
+	case token.LT_EQ:
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) <= 0)
+	case token.GT_EQ:
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) >= 0)
+	case token.EQ:
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) == 0)
+	case token.NOT_EQ:
+		return nativeBoolToBooleanObject(leftVal.Cmp(rightVal) != 0)
+	}
+
+	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+// maxIntegerBits limits the size of the integers ** and << can create, so that a single expression cannot exhaust
+// memory
+const maxIntegerBits = 1 << 20
+
+// evalIntegerPower raises base to the power of exponent. A negative exponent gives a float
+func evalIntegerPower(base, exponent *big.Int) object.Object {
+	if exponent.Sign() < 0 {
+		b, _ := new(big.Float).SetInt(base).Float64()
+		e, _ := new(big.Float).SetInt(exponent).Float64()
+		return &object.Float{Value: math.Pow(b, e)}
+	}
+
+	// the result has at least (bits in base - 1) * exponent bits, bases of 0, 1 and -1 never grow
+	if base.CmpAbs(big.NewInt(1)) > 0 {
+		if !exponent.IsInt64() || exponent.Int64() > maxIntegerBits ||
+			int64(base.BitLen()-1)*exponent.Int64() > maxIntegerBits {
+			return newError("integer result of %s ** %s is too large", base, exponent)
+		}
+	}
+
+	return object.IntegerFromBig(new(big.Int).Exp(base, exponent, nil))
+}
+
+// evalIntegerShift shifts value left or right by count bits. Right shifts are arithmetic, keeping the sign of value
+func evalIntegerShift(operator string, value, count *big.Int) object.Object {
+	if count.Sign() < 0 {
+		return newError("negative shift count: %s", count)
+	}
+
+	if operator == token.SHIFT_RIGHT {
+		// shifting by more than the length of value always leaves 0 or -1
+		if !count.IsInt64() || count.Int64() > int64(value.BitLen()) {
+			count = big.NewInt(int64(value.BitLen()))
+		}
+		return object.IntegerFromBig(new(big.Int).Rsh(value, uint(count.Int64())))
+	}
+
+	if value.Sign() == 0 {
+		return &object.Integer{Value: 0}
+	}
+	if !count.IsInt64() || count.Int64()+int64(value.BitLen()) > maxIntegerBits {
+		return newError("integer result of %s << %s is too large", value, count)
+	}
+	return object.IntegerFromBig(new(big.Int).Lsh(value, uint(count.Int64())))
+}
+
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.BIGINT_OBJ
+}
+
+// toBigInt converts an integer or big integer object to a *big.Int
+func toBigInt(obj object.Object) *big.Int {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return big.NewInt(obj.Value)
+	case *object.BigInt:
+		return obj.Value
+	}
+	return nil
+}
+
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal, _ := toFloat(left)
+	rightVal, _ := toFloat(right)
+
+	switch operator {
+	case token.PLUS:
+		return &object.Float{Value: leftVal + rightVal}
+	case token.MINUS:
+		return &object.Float{Value: leftVal - rightVal}
+	case token.ASTERISK:
+		return &object.Float{Value: leftVal * rightVal}
+	case token.SLASH:
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: leftVal / rightVal}
+	case token.PERCENT:
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case token.POWER:
+		return &object.Float{Value: math.Pow(leftVal, rightVal)}
 	case token.LT:
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case token.GT:
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case token.LT_EQ:
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case token.GT_EQ:
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case token.EQ:
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case token.NOT_EQ:
@@ -168,38 +668,71 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 }
 
+func isNumeric(obj object.Object) bool {
+	_, ok := toFloat(obj)
+	return ok
+}
+
+// toFloat converts an integer or float object to a float64
+func toFloat(obj object.Object) (float64, bool) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value), true
+	case *object.Float:
+		return obj.Value, true
+	case *object.BigInt:
+		f, _ := new(big.Float).SetInt(obj.Value).Float64()
+		return f, true
+	}
+	return 0, false
+}
+
 func evalPrefixExpression(prefix string, right object.Object) object.Object {
 	switch prefix {
 	case token.BANG:
 		return evalBangOperatorExpression(right)
 	case token.MINUS:
 		return evalMinusOperatorExpression(right)
+	case token.TILDE:
+		return evalBitwiseNotOperatorExpression(right)
 	}
 
 	return newError("unknown operator: %s%s", prefix, right.Type())
 }
 
 func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: object.RUNTIME_ERROR}
 }
 
 func evalMinusOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: %s%s", "-", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 {
+			return object.IntegerFromBig(new(big.Int).Neg(toBigInt(right)))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return object.IntegerFromBig(new(big.Int).Neg(right.Value))
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
 	}
 
-	return &object.Integer{Value: -right.(*object.Integer).Value}
+	return newError("unknown operator: %s%s", "-", right.Type())
 }
 
-func evalBangOperatorExpression(right object.Object) object.Object {
+func evalBitwiseNotOperatorExpression(right object.Object) object.Object {
 	switch right := right.(type) {
-	case *object.Boolean:
-		return &object.Boolean{Value: !right.Value}
 	case *object.Integer:
-		return FALSE
-
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInt:
+		return object.IntegerFromBig(new(big.Int).Not(right.Value))
 	}
-	return NULL
+
+	return newError("unknown operator: %s%s", "~", right.Type())
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+	return nativeBoolToBooleanObject(!isTruthy(right))
 }
 
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
@@ -217,7 +750,7 @@ func evalProgram(stmts []ast.Statement, environment *object.Environment) object.
 	var result object.Object
 	result = NULL
 	for _, statement := range stmts {
-		result = Eval(statement, environment)
+		result = eval(statement, environment)
 
 		// if we encounter a return statement or error, break execution
 		switch result := result.(type) {
@@ -235,20 +768,275 @@ func evalBlockStatement(stmts []ast.Statement, environment *object.Environment)
 	var result object.Object
 	result = NULL
 	for _, statement := range stmts {
-		result = Eval(statement, environment)
+		result = eval(statement, environment)
+
+		if result != nil && (result.Type() == object.RETURN_VALUE_OBJ || result.Type() == object.ERROR_OBJ ||
+			result == BREAK || result == CONTINUE) {
+			return result
+		}
+	}
+
+	return result
+}
+
+// evalThrowStatement raises a value as an error. Throwing an error value raises the error it holds, which already has
+// the position where the error value was created or first raised, so throwing does not change it and the caught error
+// is the same error. Any other value is raised as a USER_ERROR carrying the value as data
+func evalThrowStatement(statement *ast.ThrowStatement, environment *object.Environment) object.Object {
+	value := eval(statement.Value, environment)
+	if isSignal(value) {
+		return value
+	}
+
+	if errorValue, ok := value.(*object.ErrorValue); ok {
+		return errorValue.Error
+	}
+
+	return &object.Error{Message: value.Inspect(), Kind: object.USER_ERROR, Data: value}
+}
+
+// evalTryExpression evaluates the body and, if it raises an error, the catch clause with the error bound as an error
+// value. The finally clause then runs whatever happened, and its result is discarded unless it raises an error,
+// returns or breaks out of a loop itself. Fatal errors are not caught and skip the finally clause
+func evalTryExpression(node *ast.TryExpression, environment *object.Environment) object.Object {
+	result := eval(node.Body, environment)
+
+	err, ok := result.(*object.Error)
+	if ok && err.Fatal {
+		return err
+	}
+
+	if ok && node.Catch != nil {
+		catchEnvironment := object.ExtendEnvironment(environment)
+		catchEnvironment.Set(node.Param.Value, &object.ErrorValue{Error: err})
+		result = eval(node.Catch, catchEnvironment)
+	}
+
+	if node.Finally != nil {
+		finally := eval(node.Finally, environment)
+		if isSignal(finally) {
+			return finally
+		}
+	}
+
+	return result
+}
+
+func evalWhileStatement(statement *ast.WhileStatement, environment *object.Environment) object.Object {
+	for {
+		condition := eval(statement.Condition, environment)
+		if isSignal(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := eval(statement.Body, environment)
+		switch {
+		case result == BREAK:
+			return NULL
+		case isError(result), result.Type() == object.RETURN_VALUE_OBJ:
+			return result
+		}
+	}
+}
+
+// evalForInExpression runs the body once for each element of an array, pair of a hash or character of a string. With
+// one variable it is bound to each array element, hash key or character, and with two variables they are bound to
+// the index and element, key and value, or index and character. Each iteration runs in its own environment enclosed
+// by the loop's, so the variables are not visible after the loop and functions created by the body keep the values
+// of their iteration. The loop evaluates to the value of the body in its last iteration, or null if the body never
+// completed
+func evalForInExpression(node *ast.ForInExpression, environment *object.Environment) object.Object {
+	iterable := eval(node.Iterable, environment)
+	if isSignal(iterable) {
+		return iterable
+	}
+
+	var keys, values []object.Object
+	switch iterable := iterable.(type) {
+	case *object.Array:
+		values = iterable.Elements
+		for i := range values {
+			keys = append(keys, &object.Integer{Value: int64(i)})
+		}
+	case *object.Hash:
+		for _, pair := range iterable.SortedPairs() {
+			keys = append(keys, pair.Key)
+			values = append(values, pair.Value)
+		}
+		// a single variable iterates the keys of a hash
+		if node.Key == nil {
+			values = keys
+		}
+	case *object.String:
+		i := 0
+		for _, ch := range iterable.Value {
+			keys = append(keys, &object.Integer{Value: int64(i)})
+			values = append(values, &object.String{Value: string(ch)})
+			i++
+		}
+	default:
+		return newError("cannot iterate over %s", iterable.Type())
+	}
 
-		if result != nil && (result.Type() == object.RETURN_VALUE_OBJ || result.Type() == object.ERROR_OBJ) {
+	var result object.Object = NULL
+	for i := range values {
+		iteration := object.ExtendEnvironment(environment)
+		if node.Key != nil {
+			iteration.Set(node.Key.Value, keys[i])
+		}
+		iteration.Set(node.Value.Value, values[i])
+
+		evaluated := eval(node.Body, iteration)
+		switch {
+		case evaluated == BREAK:
 			return result
+		case evaluated == CONTINUE:
+			continue
+		case isError(evaluated), evaluated.Type() == object.RETURN_VALUE_OBJ:
+			return evaluated
 		}
+		result = evaluated
 	}
 
 	return result
 }
 
+// evalMatchExpression evaluates the body of the first arm whose pattern matches the subject and whose guard, if it
+// has one, is truthy. The variables bound by the pattern are only visible to the guard and body of that arm
+func evalMatchExpression(node *ast.MatchExpression, environment *object.Environment) object.Object {
+	subject := eval(node.Subject, environment)
+	if isSignal(subject) {
+		return subject
+	}
+
+	for _, arm := range node.Arms {
+		armEnvironment := object.ExtendEnvironment(environment)
+
+		// an arm whose pattern does not match is skipped
+		if err := destructure(arm.Pattern, subject, armEnvironment); err != nil {
+			continue
+		}
+
+		if arm.Guard != nil {
+			guard := eval(arm.Guard, armEnvironment)
+			if isSignal(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+
+		return eval(arm.Body, armEnvironment)
+	}
+
+	return newError("no match arm matched %s", subject.Inspect())
+}
+
+// evalAssignExpression assigns to the nearest binding of an identifier, or to an element of an array or hash. A
+// compound operator such as += reads the current value of the target before evaluating the assigned value, then
+// combines them, so x += f() uses the value x had before f was called. Arrays and hashes are updated in place, so the
+// change is seen through every reference to them
+func evalAssignExpression(node *ast.AssignExpression, environment *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if node.Operator != token.ASSIGN {
+			current = evalIdentifier(target.Value, environment)
+			if isSignal(current) {
+				return current
+			}
+		}
+
+		value := evalAssignedValue(node, current, environment)
+		if isSignal(value) {
+			return value
+		}
+
+		if !environment.Assign(target.Value, value) {
+			return newError("cannot assign to undeclared identifier: %s", target.Value)
+		}
+		return value
+	case *ast.IndexExpression:
+		left := eval(target.Left, environment)
+		if isSignal(left) {
+			return left
+		}
+		index := eval(target.Index, environment)
+		if isSignal(index) {
+			return index
+		}
+
+		var current object.Object
+		if node.Operator != token.ASSIGN {
+			current = evalIndexExpression(left, index)
+			if isSignal(current) {
+				return current
+			}
+		}
+
+		value := evalAssignedValue(node, current, environment)
+		if isSignal(value) {
+			return value
+		}
+
+		return evalIndexAssignment(left, index, value)
+	}
+
+	return newError("cannot assign to %s", node.Target.String())
+}
+
+// evalAssignedValue evaluates the value of an assignment. For a compound operator such as += it is combined with
+// current, the value the target had before the assigned value was evaluated
+func evalAssignedValue(node *ast.AssignExpression, current object.Object, environment *object.Environment) object.Object {
+	value := eval(node.Value, environment)
+	if isSignal(value) || node.Operator == token.ASSIGN {
+		return value
+	}
+
+	return evalInfixExperession(strings.TrimSuffix(node.Operator, "="), current, value)
+}
+
+func evalIndexAssignment(left, index, value object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		idx, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+		if idx.Value < 0 || idx.Value >= int64(len(left.Elements)) {
+			return newError("index out of range: %d with length %d", idx.Value, len(left.Elements))
+		}
+
+		left.Elements[idx.Value] = value
+		return value
+	case *object.Hash:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: value}
+		return value
+	}
+
+	return newError("index assignment not supported: %s", left.Type())
+}
+
 func evalLetStatement(statement *ast.LetStatement, environment *object.Environment) object.Object {
-	result := Eval(statement.Value, environment)
+	result := eval(statement.Value, environment)
+
+	if isSignal(result) {
+		return result
+	}
 
-	if isError(result) {
+	if statement.Pattern != nil {
+		if err := destructure(statement.Pattern, result, environment); err != nil {
+			return err
+		}
 		return result
 	}
 
@@ -256,6 +1044,87 @@ func evalLetStatement(statement *ast.LetStatement, environment *object.Environme
 	return result
 }
 
+// destructure binds the identifiers of a pattern to the parts of value they match in environment, returning an error
+// describing the mismatch if the value does not have the shape of the pattern. The identifier _ matches anything
+// without binding it and a literal matches an equal value. An array pattern matches an array of the same length, or
+// at least as long when it ends with a rest pattern ...name which is bound to the remaining elements. A hash pattern
+// matches a hash which has all of its keys, whatever other keys the hash has
+func destructure(pattern ast.Expression, value object.Object, environment *object.Environment) *object.Error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			environment.Set(pattern.Value, value)
+		}
+	case *ast.ArrayLiteral:
+		array, ok := value.(*object.Array)
+		if !ok {
+			return newError("cannot destructure %s into %s", value.Type(), pattern.String())
+		}
+
+		elements := pattern.Elements
+		var rest *ast.Identifier
+		if n := len(elements); n > 0 {
+			if spread, ok := elements[n-1].(*ast.SpreadExpression); ok {
+				rest = spread.Value.(*ast.Identifier)
+				elements = elements[:n-1]
+			}
+		}
+
+		switch {
+		case rest == nil && len(array.Elements) != len(elements):
+			return newError("cannot destructure an array of %d elements into %s, which needs %d", len(array.Elements), pattern.String(), len(elements))
+		case len(array.Elements) < len(elements):
+			return newError("cannot destructure an array of %d elements into %s, which needs at least %d", len(array.Elements), pattern.String(), len(elements))
+		}
+
+		for i, element := range elements {
+			if err := destructure(element, array.Elements[i], environment); err != nil {
+				return err
+			}
+		}
+
+		if rest != nil && rest.Value != "_" {
+			remaining := make([]object.Object, len(array.Elements)-len(elements))
+			copy(remaining, array.Elements[len(elements):])
+			environment.Set(rest.Value, &object.Array{Elements: remaining})
+		}
+	case *ast.HashPattern:
+		hash, ok := value.(*object.Hash)
+		if !ok {
+			return newError("cannot destructure %s into %s", value.Type(), pattern.String())
+		}
+
+		for i, keyNode := range pattern.Keys {
+			// the parser only allows hashable literals as keys
+			key := eval(keyNode, environment)
+
+			pair, ok := hash.Pairs[key.(object.Hashable).HashKey()]
+			if !ok {
+				return newError("cannot destructure %s, the hash has no key %s", pattern.String(), inspectQuoted(key))
+			}
+
+			if err := destructure(pattern.Values[i], pair.Value, environment); err != nil {
+				return err
+			}
+		}
+	default:
+		// the parser only allows literals here, which match values equal to them
+		if literal := eval(pattern, environment); !objectsEqual(literal, value) {
+			return newError("cannot destructure %s into %s", inspectQuoted(value), pattern.String())
+		}
+	}
+
+	return nil
+}
+
+// inspectQuoted formats obj for an error message, quoting strings so that they are told apart from other values
+func inspectQuoted(obj object.Object) string {
+	if str, ok := obj.(*object.String); ok {
+		return fmt.Sprintf("%q", str.Value)
+	}
+	return obj.Inspect()
+}
+
 func evalIdentifier(identifier string, environment *object.Environment) object.Object {
 
 	value, ok := environment.Get(identifier)
@@ -274,28 +1143,46 @@ func evalIdentifier(identifier string, environment *object.Environment) object.O
 }
 
 func evalCallStatement(statement *ast.CallExpression, env *object.Environment) object.Object {
-	function := Eval(statement.Function, env)
+	function := eval(statement.Function, env)
 
-	if isError(function) {
+	if isSignal(function) {
 		return function
 	}
 
 	args := evalExpressions(statement.Arguments, env)
-	if len(args) == 1 && isError(args[0]) {
+	if len(args) == 1 && isSignal(args[0]) {
 		return args[0]
 	}
 
 	switch fn := function.(type) {
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		res := Eval(fn.Body, extendedEnv)
+		if err := checkArity(fn, args); err != nil {
+			return err
+		}
+		if callDepth >= maxCallDepth {
+			return newError("maximum call depth of %d exceeded", maxCallDepth)
+		}
+		callDepth++
+		defer func() { callDepth-- }()
+
+		extendedEnv, err := extendFunctionEnv(fn, args)
+		if err != nil {
+			return err
+		}
+		res := eval(fn.Body, extendedEnv)
 
 		if returnValue, ok := res.(*object.ReturnValue); ok {
 			return returnValue.Value
 		}
 		return res
 	case *object.BuiltIn:
-		return fn.Fn(args...)
+		result := fn.Fn(args...)
+		// an error value created by a builtin such as error records where it was created
+		if errorValue, ok := result.(*object.ErrorValue); ok && !errorValue.Error.Pos.IsValid() {
+			errorValue.Error.Pos = statement.Pos()
+			errorValue.Error.End = statement.End()
+		}
+		return result
 	default:
 		return newError("not a function: %s", function.Type())
 
@@ -306,8 +1193,17 @@ func evalExpressions(expressions []ast.Expression, env *object.Environment) []ob
 	var result []object.Object
 
 	for _, e := range expressions {
-		evaluated := Eval(e, env)
-		if isError(evaluated) {
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			elements := evalSpreadExpression(spread, env)
+			if len(elements) == 1 && isSignal(elements[0]) {
+				return elements
+			}
+			result = append(result, elements...)
+			continue
+		}
+
+		evaluated := eval(e, env)
+		if isSignal(evaluated) {
 			return []object.Object{evaluated}
 		}
 		result = append(result, evaluated)
@@ -316,17 +1212,87 @@ func evalExpressions(expressions []ast.Expression, env *object.Environment) []ob
 	return result
 }
 
+// evalSpreadExpression evaluates the elements of the array being spread, or returns a single error
+func evalSpreadExpression(spread *ast.SpreadExpression, env *object.Environment) []object.Object {
+	value := eval(spread, env)
+	if isSignal(value) {
+		return []object.Object{value}
+	}
+
+	return value.(*object.Array).Elements
+}
+
+// evalIndexChain evaluates an index expression, which may be the last of a chain of index expressions such as
+// a?.["b"]["c"]. An optional index ?.[ whose left side is null short-circuits the rest of the chain, which is null
+// without evaluating any further indexes, and shortCircuited reports whether this happened
+func evalIndexChain(node *ast.IndexExpression, environment *object.Environment) (result object.Object, shortCircuited bool) {
+	var left object.Object
+	if inner, ok := node.Left.(*ast.IndexExpression); ok {
+		left, shortCircuited = evalIndexChain(inner, environment)
+		if shortCircuited {
+			return NULL, true
+		}
+		setErrorPosition(left, inner)
+	} else {
+		left = eval(node.Left, environment)
+	}
+	if isSignal(left) {
+		return left, false
+	}
+
+	if node.Optional && left == NULL {
+		return NULL, true
+	}
+
+	index := eval(node.Index, environment)
+	if isSignal(index) {
+		return index, false
+	}
+	return evalIndexExpression(left, index), false
+}
+
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
+	case left.Type() == object.ERROR_VALUE_OBJ && index.Type() == object.STRING_OBJ:
+		return evalErrorValueIndexExpression(left, index)
 	default:
 		return newError("index operator not supported: %s", left.Type())
 	}
 }
 
+// evalErrorValueIndexExpression looks up a field of an error value: its message, kind, data, or the line and column
+// it was raised at. Fields which are unknown or not set are null
+func evalErrorValueIndexExpression(errorValue, index object.Object) object.Object {
+	err := errorValue.(*object.ErrorValue).Error
+
+	switch index.(*object.String).Value {
+	case "message":
+		return &object.String{Value: err.Message}
+	case "kind":
+		return &object.String{Value: err.Kind}
+	case "data":
+		if err.Data != nil {
+			return err.Data
+		}
+	case "line":
+		if err.Pos.IsValid() {
+			return &object.Integer{Value: int64(err.Pos.Line)}
+		}
+	case "column":
+		if err.Pos.IsValid() {
+			return &object.Integer{Value: int64(err.Pos.Column)}
+		}
+	}
+
+	return NULL
+}
+
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)
 	idx := index.(*object.Integer).Value
@@ -339,6 +1305,19 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
+// evalStringIndexExpression indexes the characters (runes) of a string rather than its bytes
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	chars := []rune(str.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	max := int64(len(chars) - 1)
+
+	if idx < 0 || idx > max {
+		return NULL
+	}
+
+	return &object.String{Value: string(chars[idx])}
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 	key, ok := index.(object.Hashable)
@@ -360,8 +1339,8 @@ func evalHashLiteral(node *ast.HashLiteral, environment *object.Environment) obj
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, environment)
-		if isError(key) {
+		key := eval(keyNode, environment)
+		if isSignal(key) {
 			return key
 		}
 
@@ -370,8 +1349,8 @@ func evalHashLiteral(node *ast.HashLiteral, environment *object.Environment) obj
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, environment)
-		if isError(value) {
+		value := eval(valueNode, environment)
+		if isSignal(value) {
 			return value
 		}
 
@@ -390,15 +1369,67 @@ func isError(obj object.Object) bool {
 	return obj.Type() == object.ERROR_OBJ
 }
 
+// isSignal reports whether obj unwinds evaluation to an enclosing statement rather than being a value: an error, the
+// value of a return statement, or a break or continue. Expressions stop evaluating and pass a signal on unchanged, so
+// a break inside an if expression used as a value still leaves the loop
+func isSignal(obj object.Object) bool {
+	return isError(obj) || obj == BREAK || obj == CONTINUE ||
+		(obj != nil && obj.Type() == object.RETURN_VALUE_OBJ)
+}
+
+// checkArity returns an error naming the function when the number of arguments does not fit its parameters
+func checkArity(fn *object.Function, args []object.Object) *object.Error {
+	max := len(fn.Parameters)
+	min := max - len(fn.Defaults)
+	if len(args) >= min && (len(args) <= max || fn.Rest != nil) {
+		return nil
+	}
+
+	name := "anonymous function"
+	if fn.Name != "" {
+		name = "`" + fn.Name + "`"
+	}
+
+	want := fmt.Sprintf("%d", max)
+	if fn.Rest != nil {
+		want = fmt.Sprintf("at least %d", min)
+	} else if min != max {
+		want = fmt.Sprintf("%d to %d", min, max)
+	}
+
+	return newError("wrong number of arguments to %s. got=%d, want=%s", name, len(args), want)
+}
+
+// extendFunctionEnv binds the arguments to the parameters of fn in a new environment enclosed by the one fn was
+// defined in. Parameters without an argument take their default value, which is evaluated in the new environment so
+// that it can refer to the parameters before it. Any remaining arguments are collected into an array bound to the rest
+// parameter
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
-) *object.Environment {
+) (*object.Environment, *object.Error) {
 	env := object.ExtendEnvironment(fn.Env)
 
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if paramIdx < len(args) {
+			env.Set(param.Value, args[paramIdx])
+			continue
+		}
+
+		value := eval(fn.Defaults[param.Value], env)
+		if errObj, ok := value.(*object.Error); ok {
+			return nil, errObj
+		}
+		env.Set(param.Value, value)
+	}
+
+	if fn.Rest != nil {
+		rest := []object.Object{}
+		if len(args) > len(fn.Parameters) {
+			rest = append(rest, args[len(fn.Parameters):]...)
+		}
+		env.Set(fn.Rest.Value, &object.Array{Elements: rest})
 	}
 
-	return env
+	return env, nil
 }
@@ -1,9 +1,11 @@
 package evaluator
 
 import (
+	"monkey-interpreter/ast"
 	"monkey-interpreter/lexer"
 	"monkey-interpreter/object"
 	"monkey-interpreter/parser"
+	"strings"
 	"testing"
 )
 
@@ -84,6 +86,180 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.5", 3.5},
+		{"-2.25", -2.25},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"10 - 2.5 * 2", 5},
+		{"1e3 / 8", 125},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+
+	comparisons := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 < 1.5", true},
+		{"2.5 > 3", false},
+		{"1 == 1.0", true},
+		{"0.1 + 0.2 != 0.3", true},
+		{"!1.5", false},
+	}
+
+	for _, tt := range comparisons {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestBigIntegers(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775807 + 1", "9223372036854775808"},
+		{"-9223372036854775807 - 2", "-9223372036854775809"},
+		{"4294967296 * 4294967296", "18446744073709551616"},
+		{"(-9223372036854775807 - 1) / -1", "9223372036854775808"},
+		{"-(-9223372036854775807 - 1)", "9223372036854775808"},
+		{"let f = fn(n) { if (n < 2) { 1 } else { n * f(n - 1) } }; f(25)", "15511210043330985984000000"},
+		{"int(\"123456789012345678901234567890\")", "123456789012345678901234567890"},
+		{"int(1e20)", "100000000000000000000"},
+		{"99999999999999999999", "99999999999999999999"},
+		{"99999999999999999999 + 1", "100000000000000000000"},
+		{"0xFFFF_FFFF_FFFF_FFFF", "18446744073709551615"},
+		{"let f = fn(x) { match (x) { 99999999999999999999 => x } }; f(99999999999999999998 + 1)", "99999999999999999999"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		result, ok := evaluated.(*object.BigInt)
+		if !ok {
+			t.Errorf("object is not BigInt. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if result.Inspect() != tt.expected {
+			t.Errorf("object has wrong value. got=%s, want=%s", result.Inspect(), tt.expected)
+		}
+	}
+
+	// results which fit in an int64 are demoted back to an Integer
+	demoted := []struct {
+		input    string
+		expected int64
+	}{
+		{"(9223372036854775807 + 1) - 1", 9223372036854775807},
+		{"(4294967296 * 4294967296) / 4294967296", 4294967296},
+		{"-(9223372036854775807 + 1)", -9223372036854775808},
+		{"-9223372036854775808", -9223372036854775808},
+		{"99999999999999999999 - 99999999999999999998", 1},
+	}
+
+	for _, tt := range demoted {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+
+	comparisons := []struct {
+		input    string
+		expected bool
+	}{
+		{"9223372036854775807 + 1 > 9223372036854775807", true},
+		{"9223372036854775807 + 1 == 9223372036854775807 + 1", true},
+		{"9223372036854775807 + 2 != 9223372036854775807 + 1", true},
+		{"9223372036854775807 * 2 < 1.5", false},
+		{"!(9223372036854775807 + 1)", false},
+	}
+
+	for _, tt := range comparisons {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+
+	evaluated := testEval(`let big = 9223372036854775807 + 1; {big: "found"}[big]`)
+	str, ok := evaluated.(*object.String)
+	if !ok || str.Value != "found" {
+		t.Errorf("expected big integer hash key lookup to succeed. got=%+v", evaluated)
+	}
+}
+
+func TestArithmeticAndBitwiseOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"2 ** 0", 1},
+		{"2 ** -1", 0.5},
+		{"2.0 ** 0.5 * 2.0 ** 0.5", 2.0000000000000004},
+		{"7.5 % 2", 1.5},
+		{"12 & 10", 8},
+		{"12 | 3", 15},
+		{"12 ^ 10", 6},
+		{"~5", -6},
+		{"1 << 10", 1024},
+		{"-16 >> 2", -4},
+		{"1 >> 100", 0},
+		{"-1 >> 100", -1},
+		{"(1 << 64) >> 62", 4},
+		{"~(1 << 64) + (1 << 64)", -1},
+		{"((1 << 64) | 1) & 3", 1},
+		{"(3 ** 50) % 1000", 249},
+		{"1 / 0", "division by zero"},
+		{"1 % 0", "division by zero"},
+		{"1.5 / 0", "division by zero"},
+		{"(1 << 64) % 0", "division by zero"},
+		{"1 << -1", "negative shift count: -1"},
+		{"2 ** 10000000", "integer result of 2 ** 10000000 is too large"},
+		{"1 ** 10000000", 1},
+		{"1 << 10000000", "integer result of 1 << 10000000 is too large"},
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{"~1.5", "unknown operator: ~FLOAT"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not error for %q. got=%T (%v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message for %q. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+
+	evaluated := testEval("2 ** 100")
+	if result, ok := evaluated.(*object.BigInt); !ok || result.Inspect() != "1267650600228229401496703205376" {
+		t.Errorf("expected 2 ** 100 to be a big integer. got=%+v", evaluated)
+	}
+}
+
 func TestBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -107,6 +283,12 @@ func TestBooleanExpression(t *testing.T) {
 		{"13 != 13", false},
 		{"1 == 2", false},
 		{"1 != 2", true},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"1 >= 2", false},
+		{"1.5 <= 2", true},
+		{"9223372036854775807 + 1 >= 9223372036854775807 + 1", true},
 	}
 
 	for _, tt := range tests {
@@ -125,6 +307,92 @@ func TestNullExpression(t *testing.T) {
 	}
 }
 
+func TestLogicalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"1 && \"a\"", true},
+		{"false || 0", true},
+		// the right operand is not evaluated when the left operand decides the result
+		{"false && (1 + true)", false},
+		{"true || undefined", true},
+		{"let x = 0; false && x(); true", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+
+	errObj, ok := testEval("true && (1 + true)").(*object.Error)
+	if !ok || errObj.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("expected error from right operand. got=%+v", errObj)
+	}
+}
+
+func TestComparisons(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`"a" < "b"`, true},
+		{`"b" < "a"`, false},
+		{`"apple" < "apricot"`, true},
+		{`"ab" > "a"`, true},
+		{`"a" <= "a"`, true},
+		{`"a" >= "b"`, false},
+		{`"Z" < "a"`, true},
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"é" > "z"`, true},
+		{`[1, 2, 3] == [1, 2, 3]`, true},
+		{`[1, 2, 3] == [1, 2]`, false},
+		{`[1, [2, "x"]] == [1, [2, "x"]]`, true},
+		{`[1, 2] != [1, 3]`, true},
+		{`[1.0, 2] == [1, 2.0]`, true},
+		{`[] == []`, true},
+		{`[1, 2] < [1, 3]`, true},
+		{`[1, 2] < [1, 2, 0]`, true},
+		{`["b"] > ["a", "z"]`, true},
+		{`[1, 2] <= [1, 2]`, true},
+		{`{"a": 1, "b": [2]} == {"b": [2], "a": 1}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} != {"a": 1, "b": 2}`, true},
+		{`{} == {}`, true},
+		{`{1: "a"} == {1.0: "a"}`, true},
+		{`{0.0: "a"} == {-0.0: "a"}`, true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`[1, true] < [1, false]`, "unknown operator: BOOLEAN < BOOLEAN"},
+		{`{"a": 1} < {"a": 2}`, "unknown operator: HASH < HASH"},
+		{`[1] + [2]`, "unknown operator: ARRAY + ARRAY"},
+	}
+
+	for _, tt := range errors {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("expected error %q. got=%+v", tt.expected, errObj)
+		}
+	}
+}
+
 func TestBangExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -136,6 +404,21 @@ func TestBangExpression(t *testing.T) {
 		{"!!true", true},
 		{"!!false", false},
 		{"!!5", true},
+		{"!null", true},
+		{"!!null", false},
+		// only null and false are falsy
+		{"!0", false},
+		{"!\"\"", false},
+		{"!\"abc\"", false},
+		{"![]", false},
+		{"!{}", false},
+		{"!fn() {}", false},
+		{"!len", false},
+		{"!!\"\"", true},
+		// ! gives the boolean singletons, so the result compares equal to a literal
+		{"!true == false", true},
+		{"!false == true", true},
+		{"!true != false", false},
 	}
 
 	for _, tt := range tests {
@@ -182,6 +465,21 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	return true
 }
 
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g", result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testNullObject(t *testing.T, obj object.Object) bool {
 	if obj != NULL {
 		t.Errorf("object is not NULL. got=%T (%+v)", obj, obj)
@@ -252,6 +550,34 @@ func TestErrorHandling(t *testing.T) {
 			`{"name": "Monkey"}[fn(x) { x }];`,
 			"unusable as hash key: FUNCTION",
 		},
+		{
+			"fn(x) { x }(1, 2)",
+			"wrong number of arguments to anonymous function. got=2, want=1",
+		},
+		{
+			"let add = fn(x, y) { x + y }; add(1)",
+			"wrong number of arguments to `add`. got=1, want=2",
+		},
+		{
+			"let f = fn(x, y = 1, z = 2) { x }; f()",
+			"wrong number of arguments to `f`. got=0, want=1 to 3",
+		},
+		{
+			"let f = fn(x = 1) { x }; f(1, 2)",
+			"wrong number of arguments to `f`. got=2, want=0 to 1",
+		},
+		{
+			"let f = fn(x = 1 / 0) { x }; f()",
+			"division by zero",
+		},
+		{
+			"if (1 / 0) { 1 } else { 2 }",
+			"division by zero",
+		},
+		{
+			"let f = fn() { return 1 / 0; }; f(); 5",
+			"division by zero",
+		},
 	}
 
 	for _, tt := range tests {
@@ -270,6 +596,178 @@ func TestErrorHandling(t *testing.T) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+		expectedEnd string
+	}{
+		{"1 / 0", "1:1", "1:6"},
+		{"let x = 5;\nlet y = x + true;", "2:9", "2:17"},
+		{"let f = fn(x) {\n  x * \"a\"\n};\nf(2)", "2:3", "2:10"},
+		{"len(1)", "1:1", "1:7"},
+		{"[1, 2, -true]", "1:8", "1:13"},
+		{"unknown", "1:1", "1:8"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+
+		if errObj.Pos.String() != tt.expectedPos || errObj.End.String() != tt.expectedEnd {
+			t.Errorf("wrong position for %q. expected=%s-%s, got=%s-%s", tt.input, tt.expectedPos, tt.expectedEnd,
+				errObj.Pos, errObj.End)
+		}
+	}
+}
+
+func TestRecoverFromPanic(t *testing.T) {
+	// a nil identifier cannot come from the parser, evaluating it panics
+	node := &ast.PrefixExpression{Operator: "-", Right: (*ast.Identifier)(nil)}
+
+	errObj, ok := Eval(node, object.NewEnvironment()).(*object.Error)
+	if !ok {
+		t.Fatalf("expected panic to be recovered as an error")
+	}
+
+	if !strings.HasPrefix(errObj.Message, "internal error: ") {
+		t.Errorf("wrong error message. got=%q", errObj.Message)
+	}
+
+	if !errObj.Fatal {
+		t.Errorf("expected an internal error to be fatal")
+	}
+
+	// fatal errors cannot be caught
+	try := &ast.TryExpression{
+		Body:  &ast.BlockStatement{Statements: []ast.Statement{&ast.ExpressionStatement{Value: node}}},
+		Param: &ast.Identifier{Value: "e"},
+		Catch: &ast.BlockStatement{Statements: []ast.Statement{}},
+	}
+
+	errObj, ok = Eval(try, object.NewEnvironment()).(*object.Error)
+	if !ok || !errObj.Fatal || !strings.HasPrefix(errObj.Message, "internal error: ") {
+		t.Errorf("expected a fatal error to escape try. got=%+v", errObj)
+	}
+}
+
+func TestRunawayRecursion(t *testing.T) {
+	tests := []string{
+		"let f = fn(n) { f(n + 1) }; f(0)",
+		"let f = fn(n) { [1, {\"a\": f(n + 1) + 1}] }; f(0)",
+		"let f = fn(n) { try { f(n + 1) } finally { n } }; f(0)",
+	}
+
+	for _, input := range tests {
+		errObj, ok := testEval(input).(*object.Error)
+		if !ok || errObj.Message != "maximum call depth of 10000 exceeded" {
+			t.Errorf("expected call depth error for %q. got=%+v", input, errObj)
+			continue
+		}
+		if errObj.Fatal || errObj.Pos.Line != 1 {
+			t.Errorf("expected a positioned runtime error for %q. got=%+v", input, errObj)
+		}
+	}
+
+	// the depth is released as calls return, so deep but finite recursion still works afterwards
+	evaluated := testEval("let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(9000)")
+	testIntegerObject(t, evaluated, 9000)
+
+	// the error can be caught where the recursion started
+	evaluated = testEval("let f = fn(n) { f(n + 1) }; try { f(0) } catch (e) { e[\"kind\"] }")
+	if evaluated.Inspect() != "runtime" {
+		t.Errorf("expected the call depth error to be caught. got=%s", evaluated.Inspect())
+	}
+}
+
+func TestUnknownNode(t *testing.T) {
+	errObj, ok := Eval(&ast.Comment{}, object.NewEnvironment()).(*object.Error)
+	if !ok {
+		t.Fatalf("expected an error for a node which cannot be evaluated")
+	}
+
+	if errObj.Message != "cannot evaluate node of type *ast.Comment" || !errObj.Fatal {
+		t.Errorf("wrong error. got=%+v", errObj)
+	}
+}
+
+func TestTryExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { 1 } catch (e) { 2 }", "1"},
+		{"try { 1 / 0 } catch (e) { e[\"message\"] }", "division by zero"},
+		{"try { 1 / 0 } catch (e) { e[\"kind\"] }", "runtime"},
+		{"try { 1 / 0 } catch (e) { e[\"data\"] }", "null"},
+		{"try { throw \"oops\" } catch (e) { [e[\"message\"], e[\"kind\"], e[\"data\"]] }", "[oops, error, oops]"},
+		{"try { throw error(\"bad input\", {\"field\": \"age\"}) } catch (e) { [e[\"message\"], e[\"data\"][\"field\"]] }", "[bad input, age]"},
+		{"try { throw error(\"no data\") } catch (e) { e[\"data\"] }", "null"},
+		{"try {\n  let x = 1;\n  throw x\n} catch (e) { [e[\"line\"], e[\"column\"]] }", "[3, 3]"},
+		{"try { 1 + true } catch (e) { [e[\"line\"], e[\"column\"], e[\"nope\"]] }", "[1, 7, null]"},
+		{"try { throw 1 } catch (e) { e }", "error: 1"},
+		{"let e = error(\"not thrown\"); e[\"message\"]", "not thrown"},
+		// an error value records where it was created, and throwing it does not change it
+		{"let e =\n  error(\"x\"); [e[\"line\"], e[\"column\"]]", "[2, 3]"},
+		{"let e = error(\"x\");\ntry { throw e } catch (c) { [c[\"line\"], c[\"column\"], e[\"line\"]] }", "[1, 9, 1]"},
+		{"let e = error(\"x\"); try { throw e } catch (c) { c == e }", "true"},
+		{"let e = error(\"x\"); try { try { throw e } catch (c) { throw c } } catch (d) { [d == e, d != e] }", "[true, false]"},
+		{"let e = error(\"x\");\ntry { throw e } catch (c) {};\ntry { throw e } catch (c) { [c[\"line\"], e[\"line\"]] }", "[1, 1]"},
+		{"let e = error(\"x\"); let f = e; [e == f, e != f, e == error(\"x\")]", "[true, false, false]"},
+		{"try { throw 1 } catch (c) { [c == c, c != null] }", "[true, true]"},
+		// errors raised inside functions and loops are caught
+		{"let f = fn() { throw \"deep\" }; try { f() } catch (e) { e[\"message\"] }", "deep"},
+		{"try { for (x in [1, 2]) { if (x == 2) { throw x * 10 } } } catch (e) { e[\"data\"] }", "20"},
+		// finally always runs, and only replaces the result when it is interrupted
+		{"let log = []; try { log = push(log, 1) } finally { log = push(log, 2) }; log", "[1, 2]"},
+		{"let log = []; try { throw 1 } catch (e) { log = push(log, \"catch\") } finally { log = push(log, \"finally\") }; log", "[catch, finally]"},
+		{"try { 1 } catch (e) { 2 } finally { 3 }", "1"},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", "2"},
+		{"let f = fn() { try { return 1 } catch (e) { 0 } }; f()", "1"},
+		{"let n = 0; while (true) { try { break } finally { n = 5 } }; n", "5"},
+		// errors escape a try without a catch clause, after running finally
+		{"let log = []; try { try { throw \"inner\" } finally { log = push(log, \"f\") } } catch (e) { [log, e[\"message\"]] }", "[[f], inner]"},
+		// a rethrown error keeps the position it was first raised at
+		{"try {\n  try { 1 / 0 } catch (e) { throw e }\n} catch (e) { e[\"line\"] }", "2"},
+		{"try { try { throw \"a\" } catch (e) { throw \"b\" } } catch (e) { e[\"message\"] }", "b"},
+		// the caught error is only bound inside the catch clause
+		{"let e = 5; try { throw 1 } catch (e) { e }; e", "5"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"throw \"uncaught\"", "uncaught"},
+		{"throw error(\"bad\", 1)", "bad"},
+		{"1 / 0 ? 1 : 2", "division by zero"},
+		{"try { 1 } finally { throw \"from finally\" }", "from finally"},
+		{"try { throw 1 } catch (e) { e + 1 }", "type mismatch: ERROR_VALUE + INTEGER"},
+	}
+
+	for _, tt := range errorTests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%+v", tt.input, tt.expected, errObj)
+		}
+	}
+
+	errObj, ok := testEval("throw error(\"bad\", 1)").(*object.Error)
+	if !ok || errObj.Kind != object.USER_ERROR || errObj.Data.Inspect() != "1" || errObj.Pos.Line != 1 {
+		t.Errorf("wrong uncaught error. got=%+v", errObj)
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests :=
 		[]struct {
@@ -287,6 +785,55 @@ func TestLetStatements(t *testing.T) {
 	}
 }
 
+func TestDestructuringLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = [1, 2]; a + b", "3"},
+		{"let [first, ...rest] = [1, 2, 3]; [first, rest]", "[1, [2, 3]]"},
+		{"let [x, ...rest] = [1]; rest", "[]"},
+		{"let [_, second, _] = [1, 2, 3]; second", "2"},
+		{"let [[a, b], c] = [[1, 2], 3]; [a, b, c]", "[1, 2, 3]"},
+		{"let {name, age} = {\"name\": \"ann\", \"age\": 30, \"city\": \"x\"}; [name, age]", "[ann, 30]"},
+		{"let {\"full name\": n, years: y} = {\"full name\": \"ann\", \"years\": 30}; [n, y]", "[ann, 30]"},
+		{"let {point: [x, y]} = {\"point\": [3, 4]}; x * y", "12"},
+		{"let divmod = fn(a, b) { [a / b, a % b] }; let [q, r] = divmod(17, 5); [q, r]", "[3, 2]"},
+		{"let [a, b] = [1, 2]", "[1, 2]"},
+		{"let [\"point\", x, y] = [\"point\", 3, 4]; x + y", "7"},
+		{"let {1: one, true: yes} = {1: \"a\", true: \"b\"}; one + yes", "ab"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = 5;", "cannot destructure INTEGER into [a, b]"},
+		{"let [a, b] = [1, 2, 3];", "cannot destructure an array of 3 elements into [a, b], which needs 2"},
+		{"let [a, b, ...rest] = [1];", "cannot destructure an array of 1 elements into [a, b, ...rest], which needs at least 2"},
+		{"let {name} = [1];", "cannot destructure ARRAY into {name}"},
+		{"let {name, age} = {\"name\": \"ann\"};", "cannot destructure {name, age}, the hash has no key \"age\""},
+		{"let [a, [b, c]] = [1, 2];", "cannot destructure INTEGER into [b, c]"},
+		{"let [1, x] = [2, 3];", "cannot destructure 2 into 1"},
+		{"let [\"point\", x] = [\"line\", 3];", "cannot destructure \"line\" into point"},
+		{"let {1: a} = {\"1\": 2};", "cannot destructure {1: a}, the hash has no key 1"},
+	}
+
+	for _, tt := range errorTests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%+v", tt.input, tt.expected, errObj)
+		}
+	}
+}
+
 func TestFunctionObject(t *testing.T) {
 	input := "fn(x) { x + 2; };"
 	evaluated := testEval(input)
@@ -337,6 +884,336 @@ func TestFunctionApplication(t *testing.T) {
 	}
 }
 
+func TestDefaultParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let add = fn(a, b = 10) { a + b }; add(1)", 11},
+		{"let add = fn(a, b = 10) { a + b }; add(1, 2)", 3},
+		{"let f = fn(a = 1, b = 2) { a * 10 + b }; f()", 12},
+		{"let f = fn(a = 1, b = 2) { a * 10 + b }; f(3)", 32},
+		{"let f = fn(a, b = a * 2) { a + b }; f(5)", 15},
+		{"let n = 7; let f = fn(a = n) { a }; let n = 8; f()", 8},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestRestParametersAndSpread(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, ...rest) { rest }; f(1, 2, 3)", "[2, 3]"},
+		{"let f = fn(a, ...rest) { rest }; f(1)", "[]"},
+		{"let f = fn(...args) { len(args) }; f()", "0"},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1)", "[1, 2, []]"},
+		{"let f = fn(a, b = 2, ...rest) { [a, b, rest] }; f(1, 3, 4, 5)", "[1, 3, [4, 5]]"},
+		{"let add = fn(a, b) { a + b }; let xs = [1, 2]; add(...xs)", "3"},
+		{"let add = fn(a, b, c) { a + b + c }; add(1, ...[2, 3])", "6"},
+		{"let a = [1, 2]; let b = [3]; [0, ...a, ...b, 4]", "[0, 1, 2, 3, 4]"},
+		{"[...[]]", "[]"},
+		{"let forward = fn(f, ...args) { f(...args) }; forward(fn(x, y) { x * y }, 6, 7)", "42"},
+		{"len(...[\"abc\"])", "3"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b, ...rest) { a }; f(1)", "wrong number of arguments to `f`. got=1, want=at least 2"},
+		{"let f = fn(a, b) { a }; f(...[1, 2, 3])", "wrong number of arguments to `f`. got=3, want=2"},
+		{"[...1]", "cannot spread INTEGER, expected ARRAY"},
+		{"[...(1 / 0)]", "division by zero"},
+	}
+
+	for _, tt := range errorTests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("expected error %q for %q. got=%+v", tt.expected, tt.input, errObj)
+		}
+	}
+}
+
+func TestWhileStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1; }; i", 5},
+		{"let i = 0; let sum = 0; while (i < 10) { let i = i + 1; if (i == 3) { continue; } let sum = sum + i; }; sum", 52},
+		{"let i = 0; while (true) { let i = i + 1; if (i == 7) { break; } }; i", 7},
+		{"while (false) { 1 }", nil},
+		{"let f = fn() { let i = 0; while (true) { let i = i + 1; if (i > 2) { return i * 10; } } }; f()", 30},
+		// break and continue only unwind to the innermost loop
+		{"let n = 0; let i = 0; while (i < 3) { let i = i + 1; let j = 0; while (true) { let j = j + 1; let n = n + 1; if (j == 2) { break; } } }; n", 6},
+		// enough iterations that recursion would exhaust the stack
+		{"let i = 0; while (i < 100000) { let i = i + 1; }; i", 100000},
+		// break and continue inside an expression still unwind the loop
+		{"let i = 0; let n = 0; while (i < 3) { i += 1; [if (true) { continue }]; n += 1 }; n", 0},
+		{"let i = 0; let y = 0; while (i < 5) { i += 1; y = if (i == 2) { break } else { i } }; y", 1},
+		{"let i = 0; while (i < 5) { i += 1; let y = if (true) { break } else { 1 }; }; i", 1},
+		{"let i = 0; let n = 0; while (i < 3) { i += 1; n += 1 + if (i > 1) { continue } else { 0 }; puts(if (true) { continue }) }; n", 1},
+		{"let f = fn() { let x = if (true) { return 1 } else { 2 }; 3 }; f()", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+
+	errObj, ok := testEval("while (1 / 0) { 1 }").(*object.Error)
+	if !ok || errObj.Message != "division by zero" {
+		t.Errorf("expected error from condition. got=%+v", errObj)
+	}
+}
+
+func TestForInExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { sum = sum + x; }; sum", "6"},
+		{"let out = []; for (i, x in [\"a\", \"b\"]) { out = push(out, [i, x]); }; out", "[[0, a], [1, b]]"},
+		{"let out = []; for (k in {\"b\": 2, \"a\": 1, \"c\": 3}) { out = push(out, k); }; out", "[a, b, c]"},
+		{"let out = []; for (k, v in {3: \"c\", 1: \"a\", 2: \"b\"}) { out = push(out, [k, v]); }; out", "[[1, a], [2, b], [3, c]]"},
+		{"let out = []; for (c in \"héllo\") { out = push(out, c); }; out", "[h, é, l, l, o]"},
+		{"let out = []; for (i, c in \"🙈x\") { out = push(out, i); }; out", "[0, 1]"},
+		{"let out = []; for (x in [1, 2, 3, 4, 5]) { if (x == 2) { continue; } if (x == 4) { break; } out = push(out, x); }; out", "[1, 3]"},
+		{"for (x in [1, 2, 3]) { x * 10 }", "30"},
+		{"for (x in [1, 2, 3]) { if (x == 2) { break; } x * 10 }", "10"},
+		{"for (x in []) { x }", "null"},
+		{"let f = fn(xs) { for (x in xs) { if (x > 1) { return x; } } }; f([1, 5, 9])", "5"},
+		{"let n = 0; for (x in [1, 2]) { for (y in [1, 2, 3]) { if (y == 2) { break; } n = n + 1; } }; n", "2"},
+		// loop variables and lets in the body are scoped to each iteration
+		{"let x = 10; for (x in [1, 2]) { x }; x", "10"},
+		{"let i = 10; for (i, v in [\"a\"]) { i }; i", "10"},
+		{"let fs = []; for (x in [1, 2, 3]) { fs = push(fs, fn() { x }) }; [fs[0](), fs[1](), fs[2]()]", "[1, 2, 3]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errObj, ok := testEval("for (x in 5) { x }").(*object.Error)
+	if !ok || errObj.Message != "cannot iterate over INTEGER" {
+		t.Errorf("expected iteration error. got=%+v", errObj)
+	}
+
+	errObj, ok = testEval("for (x in [1, 2]) { let y = x }; y").(*object.Error)
+	if !ok || errObj.Message != "unknown identifier: y" {
+		t.Errorf("expected body bindings to be scoped to the loop. got=%+v", errObj)
+	}
+}
+
+func TestSelfReferencingValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1]; a[0] = a; a", "[[...]]"},
+		{"let h = {}; h[\"self\"] = h; h", "{self: {...}}"},
+		{"let a = [1]; a[0] = a; \"a is ${a}\"", "a is [[...]]"},
+		{"let a = [1]; a[0] = a; a == a", "true"},
+		{"let a = [1]; a[0] = a; let b = [1]; b[0] = b; a == b", "true"},
+		{"let h = {}; h[\"self\"] = h; let g = {}; g[\"self\"] = g; [h == g, h != g]", "[true, false]"},
+		{"let a = [0, 1]; a[0] = a; let b = [0, 2]; b[0] = b; [a == b, a < b, a > b]", "[false, true, false]"},
+		{"let a = [1]; a[0] = a; [a < a, a <= a]", "[false, true]"},
+		{"let a = [1]; a[0] = a; match (a) { [x] => x == a }", "true"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errObj, ok := testEval("let a = [1]; a[0] = a; match (a) { [] => 0 }").(*object.Error)
+	if !ok || errObj.Message != "no match arm matched [[...]]" {
+		t.Errorf("wrong error. got=%+v", errObj)
+	}
+}
+
+func TestElseIfExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(x) { if (x < 0) { \"negative\" } else if (x == 0) { \"zero\" } else { \"positive\" } }; [f(-1), f(0), f(1)]", "[negative, zero, positive]"},
+		{"if (false) { 1 } else if (false) { 2 }", "null"},
+		{"let f = fn(x) { if (x == 1) { return 10; } else if (x == 2) { return 20; } 30 }; [f(1), f(2), f(3)]", "[10, 20, 30]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestConditionalExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"true ? 1 : 2", "1"},
+		{"false ? 1 : 2", "2"},
+		{"1 < 2 ? \"yes\" : \"no\"", "yes"},
+		{"0 ? \"truthy\" : \"falsy\"", "truthy"},
+		{"let sign = fn(x) { x < 0 ? -1 : x == 0 ? 0 : 1 }; [sign(-5), sign(0), sign(5)]", "[-1, 0, 1]"},
+		{"let abs = fn(x) { x < 0 ? -x : x }; abs(-3) + abs(4)", "7"},
+		// only the chosen branch is evaluated
+		{"true ? 1 : 1 / 0", "1"},
+		{"let n = 0; false ? n = 1 : n = 2; n", "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errObj, ok := testEval("1 / 0 ? 1 : 2").(*object.Error)
+	if !ok || errObj.Message != "division by zero" {
+		t.Errorf("expected condition error. got=%+v", errObj)
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (2) { 1 => \"one\", 2 => \"two\", _ => \"many\" }", "two"},
+		{"match (7) { 1 => \"one\", _ => \"many\" }", "many"},
+		{"match (\"b\") { \"a\" => 1, \"b\" => 2 }", "2"},
+		{"match (-1.5) { -1.5 => true, _ => false }", "true"},
+		{"match (2.0) { 2 => \"equal\" }", "equal"},
+		{"match (false) { true => 1, false => 0 }", "0"},
+		{"match (5) { n => n * 2 }", "10"},
+		{"match ([1, 2]) { [] => 0, [a] => a, [a, b] => a + b }", "3"},
+		{"match ([1, 2, 3]) { [a, b] => 0, [first, ...rest] => rest }", "[2, 3]"},
+		{"match ([1]) { [first, ...rest] => rest }", "[]"},
+		{"match ([1, [2, 3]]) { [_, [x, y]] => x * y }", "6"},
+		{"match ({\"name\": \"ann\", \"age\": 30}) { {\"name\": n} => n }", "ann"},
+		{"match ({\"a\": 1}) { {\"b\": b} => b, {\"a\": 2} => 2, {\"a\": a} => a * 10 }", "10"},
+		{"match ({\"name\": \"ann\", \"tags\": [1, 2]}) { {name, tags: [first, ...rest]} => [name, first, rest] }", "[ann, 1, [2]]"},
+		{"match (\"x\") { [a] => a, {\"a\": a} => a, _ => \"other\" }", "other"},
+		{"match (5) { n if n < 0 => \"negative\", n if n > 0 => \"positive\", _ => \"zero\" }", "positive"},
+		{"match ([3, 4]) { [a, b] if a > b => a, [a, b] => b }", "4"},
+		// bindings are scoped to their arm
+		{"let n = 1; match (5) { n => n }; n", "1"},
+		{"let f = fn(xs) { match (xs) { [] => 0, [x, ...rest] => x + f(rest) } }; f([1, 2, 3, 4])", "10"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (3) { 1 => 1, 2 => 2 }", "no match arm matched 3"},
+		{"match ([1, 2]) { [a] => a }", "no match arm matched [1, 2]"},
+		{"match (1) { n if n + true => 1 }", "type mismatch: INTEGER + BOOLEAN"},
+		{"match (1 / 0) { _ => 1 }", "division by zero"},
+	}
+
+	for _, tt := range errorTests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%+v", tt.input, tt.expected, errObj)
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; x = 2; x", "2"},
+		{"let x = 1; x = x + 1", "2"},
+		{"let x = 1; let y = 1; x = y = 5; [x, y]", "[5, 5]"},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", "6"},
+		{"let s = \"a\"; s += \"b\"; s", "ab"},
+		{"let i = 0; while (i < 3) { i += 1 }; i", "3"},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x }; sum", "6"},
+		// assignment updates the nearest enclosing binding
+		{"let counter = fn() { let n = 0; fn() { n += 1 } }; let c = counter(); c(); c(); c()", "3"},
+		{"let x = 1; let f = fn() { x = 2 }; f(); x", "2"},
+		{"let x = 1; let f = fn(x) { x = 5 }; f(0); x", "1"},
+		// arrays and hashes are updated in place and shared by reference
+		{"let a = [1, 2, 3]; a[0] = 10; a", "[10, 2, 3]"},
+		{"let a = [1, 2, 3]; let b = a; b[1] = 20; a", "[1, 20, 3]"},
+		{"let a = [1, 2]; a[1] *= 21; a[1]", "42"},
+		{"let a = [[1, 2], [3]]; a[0][1] = 5; a", "[[1, 5], [3]]"},
+		{"let h = {\"a\": 1}; h[\"b\"] = 2; h[\"a\"] += 10; h", "{a: 11, b: 2}"},
+		{"let h = {}; let f = fn(m) { m[1] = true }; f(h); h", "{1: true}"},
+		{"let a = [1]; let b = push(a, 2); a", "[1]"},
+		{"let a = [1, 2]; a[0] = 3", "3"},
+		// a compound assignment reads the target before evaluating the assigned value
+		{"let x = 1; let f = fn() { x = 10; 1 }; x += f(); x", "2"},
+		{"let a = [1]; let f = fn() { a[0] = 10; 1 }; a[0] += f(); a", "[2]"},
+		{"let h = {\"n\": 1}; h[\"n\"] -= (h[\"n\"] = 5); h", "{n: -4}"},
+		{"let x = 1; let f = fn() { x = 10; 1 }; x = x + f(); x", "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 1", "cannot assign to undeclared identifier: x"},
+		{"y += 1", "unknown identifier: y"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1 with length 1"},
+		{"let a = [1]; a[-1] = 2", "index out of range: -1 with length 1"},
+		{"let a = [1]; a[\"0\"] = 2", "array index must be INTEGER, got STRING"},
+		{"let h = {}; h[[1]] = 2", "unusable as hash key: ARRAY"},
+		{"let s = \"abc\"; s[0] = \"x\"", "index assignment not supported: STRING"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1; x /= 0", "division by zero"},
+	}
+
+	for _, tt := range errorTests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("expected error %q for %q. got=%+v", tt.expected, tt.input, errObj)
+		}
+	}
+}
+
 func TestClosures(t *testing.T) {
 	input := `
 let newAdder = fn(x) {
@@ -385,6 +1262,9 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("Hello world")`, 11},
+		{`len("héllo 🙈")`, 7},
+		{`byte_len("héllo 🙈")`, 11},
+		{`byte_len([1])`, "argument to `byte_len` must be STRING, got ARRAY"},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 		{`len([1, 2, 3, 4])`, 4},
@@ -393,6 +1273,24 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`rest([6, 7, 8])`, []int64{7, 8}},
 		{`push([6, 7, 8], 9)`, []int64{6, 7, 8, 9}},
 		{`puts("hello", "world")`, nil},
+		{`float(3)`, 3.0},
+		{`float("2.5")`, 2.5},
+		{`float("abc")`, "could not convert \"abc\" to FLOAT"},
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int("42")`, 42},
+		{`int(1e308 * 10)`, "could not convert +Inf to INTEGER"},
+		{`round(2.5)`, 3.0},
+		{`round(-2.4)`, -2.0},
+		{`round(7)`, 7},
+		{`floor(2.7)`, 2.0},
+		{`floor(-2.1)`, -3.0},
+		{`floor("x")`, "argument to `floor` must be INTEGER or FLOAT, got STRING"},
+		{`sqrt(16)`, 4.0},
+		{`sqrt(2.25)`, 1.5},
+		{`sqrt(-1)`, "argument to `sqrt` must not be negative, got -1"},
+		{`format_int(10, 1)`, "base for `format_int` must be between 2 and 36, got 1"},
+		{`format_int("10", 2)`, "first argument to `format_int` must be INTEGER, got STRING"},
 	}
 
 	for _, tt := range tests {
@@ -401,6 +1299,8 @@ func TestBuiltinFunctions(t *testing.T) {
 		switch expected := tt.expected.(type) {
 		case int:
 			testIntegerObject(t, evaluated, int64(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
 		case string:
 			errObj, ok := evaluated.(*object.Error)
 			if !ok {
@@ -414,6 +1314,33 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestFormatInt(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`format_int(255, 16)`, "ff"},
+		{`format_int(-10, 2)`, "-1010"},
+		{`format_int(0o17, 8)`, "17"},
+		{`format_int(1_000, 10)`, "1000"},
+		{`format_int(35, 36)`, "z"},
+		{`format_int(9223372036854775807 + 1, 16)`, "8000000000000000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -513,6 +1440,14 @@ func TestHashIndexExpressions(t *testing.T) {
 		{`{5 : 5}[5]`, 5},
 		{`{true : 5}[true]`, 5},
 		{`{false : 5}[false]`, 5},
+		// keys which are == find the same pair
+		{`{0.0: 5}[-0.0]`, 5},
+		{`{-0.0: 5}[0]`, 5},
+		{`{1: 5}[1.0]`, 5},
+		{`{1.0: 5}[1]`, 5},
+		{`{1.5: 5}[1.5]`, 5},
+		{`{1: 5}[1.5]`, nil},
+		{`{1180591620717411303424: 5}[1180591620717411303424.0]`, 5},
 	}
 
 	for _, tt := range tests {
@@ -526,3 +1461,129 @@ func TestHashIndexExpressions(t *testing.T) {
 		}
 	}
 }
+
+func TestNullAndOptionalIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"null", "null"},
+		{"null == null", "true"},
+		{"null != null", "false"},
+		{"1 == null", "false"},
+		{"[1][5] == null", "true"},
+		{"\"a\" != null", "true"},
+		{"null ?? 5", "5"},
+		{"0 ?? 5", "0"},
+		{"false ?? 5", "false"},
+		{"{\"a\": 1}[\"b\"] ?? \"default\"", "default"},
+		{"null ?? null ?? 3", "3"},
+		// the right operand is only evaluated when needed
+		{"1 ?? 1 / 0", "1"},
+		{"let config = {\"db\": {\"port\": 5432}}; config?.[\"db\"]?.[\"port\"]", "5432"},
+		{"let config = {\"db\": {\"port\": 5432}}; config?.[\"cache\"]?.[\"port\"] ?? 6379", "6379"},
+		{"let xs = null; xs?.[0]", "null"},
+		{"let xs = null; xs?.[1 / 0]", "null"},
+		{"[1, 2]?.[1]", "2"},
+		// an optional index short-circuits the rest of the chain
+		{"let h = null; h?.[\"a\"][\"b\"][0]", "null"},
+		{"let h = {\"a\": null}; h[\"a\"]?.[\"b\"][\"c\"]", "null"},
+		{"let h = {\"a\": {\"b\": [1, 2]}}; h?.[\"a\"][\"b\"][1]", "2"},
+		{"let n = 0; let h = null; h?.[n = 1][n = 2]; n", "0"},
+		// null is falsy, like false
+		{"if (null) { 1 } else { 2 }", "2"},
+		{"[null && 1, null || 1, 1 && null]", "[false, true, false]"},
+		{"null ? \"yes\" : \"no\"", "no"},
+		{"let n = 0; while (null) { n = 1 }; n", "0"},
+		{"match (1) { x if null => 1, _ => 2 }", "2"},
+		{"match (null) { null => \"none\", _ => \"some\" }", "none"},
+		{"let f = fn(x = null) { x ?? \"unset\" }; [f(), f(1)]", "[unset, 1]"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. expected=%s, got=%s", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"null[0]", "index operator not supported: NULL"},
+		{"5?.[0]", "index operator not supported: INTEGER"},
+		{"let h = {\"a\": null}; h?.[\"a\"][\"b\"]", "index operator not supported: NULL"},
+		{"null + 1", "type mismatch: NULL + INTEGER"},
+		{"null ?? 1 / 0", "division by zero"},
+	}
+
+	for _, tt := range errorTests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok || errObj.Message != tt.expected {
+			t.Errorf("wrong error for %q. expected=%q, got=%+v", tt.input, tt.expected, errObj)
+		}
+	}
+}
+
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"abc"[0]`, "a"},
+		{`"héllo"[1]`, "é"},
+		{`"🙈🙉🙊"[2]`, "🙊"},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. expected=%q, got=%q", expected, str.Value)
+			}
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestInterpolatedStrings(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let a = 2; let b = 3; "total: ${a + b}"`, "total: 5"},
+		{`let name = "monkey"; "hello ${name}!"`, "hello monkey!"},
+		{`"${[1, 2]} ${true} ${fn(x) { x }(4)}"`, "[1, 2] true 4"},
+		{`let n = 1; "outer ${"inner ${n + 1}"}"`, "outer inner 2"},
+		{`"\${not interpolated}"`, "${not interpolated}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object is not String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if str.Value != tt.expected {
+			t.Errorf("String has wrong value. expected=%q, got=%q", tt.expected, str.Value)
+		}
+	}
+
+	errObj, ok := testEval(`"a ${1 + true}"`).(*object.Error)
+	if !ok || errObj.Message != "type mismatch: INTEGER + BOOLEAN" {
+		t.Errorf("expected error from embedded expression. got=%+v", errObj)
+	}
+}
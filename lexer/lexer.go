@@ -4,25 +4,82 @@
 package lexer
 
 import (
+	"fmt"
+	"monkey-interpreter/diagnostic"
 	"monkey-interpreter/token"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 type Lexer struct {
 	input        string
-	position     int  // current position in input (points to current char)
-	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	filename     string
+	comments     bool // emit comments as tokens rather than skipping them
+	position     int  // current byte offset in input (points to current char)
+	readPosition int  // current reading byte offset in input (after current char)
+	ch           rune // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char, counted in characters rather than bytes
+	diagnostics  []diagnostic.Diagnostic
+
+	// unclosed braces within each interpolated string expression being lexed, innermost last
+	interpolations []int
+}
+
+// Option configures optional behaviour of a Lexer.
+type Option func(*Lexer)
+
+// WithFilename records the name of the file being lexed in the position of every token.
+func WithFilename(filename string) Option {
+	return func(l *Lexer) {
+		l.filename = filename
+	}
+}
+
+// WithComments emits comments as COMMENT tokens rather than skipping them, so that tools such as formatters can
+// preserve them.
+func WithComments() Option {
+	return func(l *Lexer) {
+		l.comments = true
+	}
 }
 
-func New(input string) *Lexer {
-	l := &Lexer{input: input}
+func New(input string, options ...Option) *Lexer {
+	l := &Lexer{input: input, line: 1, column: 1}
+	for _, option := range options {
+		option(l)
+	}
+
 	l.readChar()
 	return l
 }
 
+// Diagnostics returns the problems found so far while lexing, in the order they were found. Every ILLEGAL token has
+// a corresponding diagnostic.
+func (l *Lexer) Diagnostics() []diagnostic.Diagnostic {
+	return l.diagnostics
+}
+
+// errorAt records an error diagnostic spanning from start up to and including the current char
+func (l *Lexer) errorAt(start token.Position, format string, a ...interface{}) {
+	end := l.pos()
+	end.Offset = l.readPosition
+	end.Column++
+
+	l.diagnostics = append(l.diagnostics, diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Message:  fmt.Sprintf(format, a...),
+		Pos:      start,
+		End:      end,
+	})
+}
+
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 	l.eatWhitespace()
+	start := l.pos()
 
 	switch l.ch {
 	case 0:
@@ -34,13 +91,29 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Type = token.EQ
 			tok.Literal = string(l.ch) + string(peek)
 			l.readChar()
+		} else if peek == '>' {
+			tok.Type = token.ARROW
+			tok.Literal = "=>"
+			l.readChar()
 		} else {
 			tok = token.New(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = token.New(token.PLUS, l.ch)
+		if l.peekChar() == '=' {
+			tok.Type = token.PLUS_ASSIGN
+			tok.Literal = "+="
+			l.readChar()
+		} else {
+			tok = token.New(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = token.New(token.MINUS, l.ch)
+		if l.peekChar() == '=' {
+			tok.Type = token.MINUS_ASSIGN
+			tok.Literal = "-="
+			l.readChar()
+		} else {
+			tok = token.New(token.MINUS, l.ch)
+		}
 	case '!':
 		peek := l.peekChar()
 		if peek == '=' {
@@ -51,21 +124,111 @@ func (l *Lexer) NextToken() token.Token {
 			tok = token.New(token.BANG, l.ch)
 		}
 	case '*':
-		tok = token.New(token.ASTERISK, l.ch)
+		if l.peekChar() == '*' {
+			tok.Type = token.POWER
+			tok.Literal = "**"
+			l.readChar()
+		} else if l.peekChar() == '=' {
+			tok.Type = token.ASTERISK_ASSIGN
+			tok.Literal = "*="
+			l.readChar()
+		} else {
+			tok = token.New(token.ASTERISK, l.ch)
+		}
+	case '%':
+		tok = token.New(token.PERCENT, l.ch)
+	case '^':
+		tok = token.New(token.CARET, l.ch)
+	case '~':
+		tok = token.New(token.TILDE, l.ch)
+	case '?':
+		switch l.peekChar() {
+		case '?':
+			tok.Type = token.NULLISH
+			tok.Literal = "??"
+			l.readChar()
+		case '.':
+			tok.Type = token.OPTIONAL
+			tok.Literal = "?."
+			l.readChar()
+		default:
+			tok = token.New(token.QUESTION, l.ch)
+		}
 	case '/':
-		tok = token.New(token.SLASH, l.ch)
+		peek := l.peekChar()
+		if peek == '/' || peek == '*' {
+			tok = l.readComment()
+			if tok.Type == token.COMMENT && !l.comments {
+				l.readChar()
+				return l.NextToken()
+			}
+		} else if peek == '=' {
+			tok.Type = token.SLASH_ASSIGN
+			tok.Literal = "/="
+			l.readChar()
+		} else {
+			tok = token.New(token.SLASH, l.ch)
+		}
+	case '&':
+		if l.peekChar() == '&' {
+			tok.Type = token.AND
+			tok.Literal = "&&"
+			l.readChar()
+		} else {
+			tok = token.New(token.AMPERSAND, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' {
+			tok.Type = token.OR
+			tok.Literal = "||"
+			l.readChar()
+		} else {
+			tok = token.New(token.PIPE, l.ch)
+		}
 	case '<':
-		tok = token.New(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			tok.Type = token.LT_EQ
+			tok.Literal = "<="
+			l.readChar()
+		} else if l.peekChar() == '<' {
+			tok.Type = token.SHIFT_LEFT
+			tok.Literal = "<<"
+			l.readChar()
+		} else {
+			tok = token.New(token.LT, l.ch)
+		}
 	case '>':
-		tok = token.New(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			tok.Type = token.GT_EQ
+			tok.Literal = ">="
+			l.readChar()
+		} else if l.peekChar() == '>' {
+			tok.Type = token.SHIFT_RIGHT
+			tok.Literal = ">>"
+			l.readChar()
+		} else {
+			tok = token.New(token.GT, l.ch)
+		}
 	case '(':
 		tok = token.New(token.LPAREN, l.ch)
 	case ')':
 		tok = token.New(token.RPAREN, l.ch)
 	case '{':
+		if n := len(l.interpolations); n > 0 {
+			l.interpolations[n-1]++
+		}
 		tok = token.New(token.LBRACE, l.ch)
 	case '}':
-		tok = token.New(token.RBRACE, l.ch)
+		if n := len(l.interpolations); n > 0 && l.interpolations[n-1] == 0 {
+			// the end of an interpolated expression, carry on reading the rest of the string
+			l.interpolations = l.interpolations[:n-1]
+			tok = l.readStringToken(start, token.STRING_TAIL, token.STRING_MIDDLE)
+		} else {
+			if n > 0 {
+				l.interpolations[n-1]--
+			}
+			tok = token.New(token.RBRACE, l.ch)
+		}
 	case '[':
 		tok = token.New(token.LBRACKET, l.ch)
 	case ']':
@@ -74,26 +237,58 @@ func (l *Lexer) NextToken() token.Token {
 		tok = token.New(token.COMMA, l.ch)
 	case ';':
 		tok = token.New(token.SEMICOLON, l.ch)
+	case '.':
+		if l.peekChar() == '.' && l.peekNthChar(2) == '.' {
+			tok.Type = token.ELLIPSIS
+			tok.Literal = "..."
+			l.readChar()
+			l.readChar()
+		} else {
+			tok = l.illegalCharacter(start)
+		}
 	case '"':
-		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok = l.readStringToken(start, token.STRING, token.STRING_HEAD)
+	case '`':
+		value, ok := l.readRawString()
+		tok = l.stringToken(value, ok, start)
 	case ':':
 		tok = token.New(token.COLON, l.ch)
 	default:
-		literal := l.readLiteral()
-		tok = token.FindTokenType(literal)
+		switch {
+		case isLetter(l.ch):
+			tok.Literal = l.readIdentifier()
+			tok.Type = token.LookupIdent(tok.Literal)
+		case isDigit(l.ch):
+			tok.Literal, tok.Type = l.readNumber(start)
+		default:
+			tok = l.illegalCharacter(start)
+		}
 	}
 
 	l.readChar()
+	tok.Pos = start
+	tok.End = l.pos()
 	return tok
 }
 
-func isWhitespace(ch byte) bool {
+// illegalCharacter returns an ILLEGAL token for the current char and reports it as a diagnostic
+func (l *Lexer) illegalCharacter(start token.Position) token.Token {
+	literal := l.input[l.position:l.readPosition]
+	l.errorAt(start, "illegal character %q", literal)
+	return token.Token{Type: token.ILLEGAL, Literal: literal}
+}
+
+// pos returns the position of the current char
+func (l *Lexer) pos() token.Position {
+	return token.Position{Filename: l.filename, Offset: l.position, Line: l.line, Column: l.column}
+}
+
+func isWhitespace(ch rune) bool {
 	// ascii values for tab, line feed, carriage return and space
 	return ch == 9 || ch == 10 || ch == 13 || ch == 32
 }
 
-func isQuote(ch byte) bool {
+func isQuote(ch rune) bool {
 	// ascii values for "
 	return ch == 34
 }
@@ -105,49 +300,368 @@ func (l *Lexer) eatWhitespace() {
 	}
 }
 
-func (l *Lexer) readLiteral() string {
-	literal := ""
+// isLetter reports whether ch can start an identifier
+func isLetter(ch rune) bool {
+	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_' || ch >= utf8.RuneSelf && unicode.IsLetter(ch)
+}
+
+func isDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
 
-	for l.ch != 0 {
-		literal += string(l.ch)
+// isIdentifierChar reports whether ch can appear in an identifier after the first char
+func isIdentifierChar(ch rune) bool {
+	return isLetter(ch) || isDigit(ch) || ch >= utf8.RuneSelf && unicode.IsDigit(ch)
+}
 
-		peek := l.peekChar()
-		if isWhitespace(peek) || token.IsDelimiter(peek) {
-			return literal
+// readIdentifier reads an identifier starting at the current char, leaving the lexer on its last char
+func (l *Lexer) readIdentifier() string {
+	position := l.position
+	for isIdentifierChar(l.peekChar()) {
+		l.readChar()
+	}
+	return l.input[position:l.readPosition]
+}
+
+// numberBases maps the second char of a prefixed integer literal e.g. 0x1F to the name and digits of its base
+var numberBases = map[rune]struct {
+	name    string
+	isDigit func(rune) bool
+}{
+	'x': {"hexadecimal", isHexDigit},
+	'X': {"hexadecimal", isHexDigit},
+	'o': {"octal", func(ch rune) bool { return '0' <= ch && ch <= '7' }},
+	'O': {"octal", func(ch rune) bool { return '0' <= ch && ch <= '7' }},
+	'b': {"binary", func(ch rune) bool { return ch == '0' || ch == '1' }},
+	'B': {"binary", func(ch rune) bool { return ch == '0' || ch == '1' }},
+}
+
+// readNumber reads an integer or floating point number starting at the current char, leaving the lexer on its last
+// char. Floating point numbers have a fractional part, an exponent or both e.g. 3.14, 1e9, 2.5E-3. Integers may be
+// written in hexadecimal 0xFF, octal 0o17 or binary 0b1010, and any number may separate its digits with underscores
+// e.g. 1_000_000. A malformed number is returned as an ILLEGAL token and reported as a diagnostic
+func (l *Lexer) readNumber(start token.Position) (string, token.TokenType) {
+	if base, ok := numberBases[l.peekChar()]; ok && l.ch == '0' {
+		return l.readPrefixedInteger(start, base.name, base.isDigit)
+	}
+
+	position := l.position
+	tokenType := token.TokenType(token.INT)
+
+	l.readDigits(isDigit)
+
+	if l.peekChar() == '.' && isDigit(l.peekNthChar(2)) {
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits(isDigit)
+	}
+
+	if peek := l.peekChar(); peek == 'e' || peek == 'E' {
+		next := l.peekNthChar(2)
+		if isDigit(next) || (next == '+' || next == '-') && isDigit(l.peekNthChar(3)) {
+			tokenType = token.FLOAT
+			l.readChar()
+			if !isDigit(l.peekChar()) {
+				l.readChar()
+			}
+			l.readDigits(isDigit)
+		}
+	}
+
+	literal := l.input[position:l.readPosition]
+	switch {
+	case !hasValidSeparators(literal, isDigit):
+		l.errorAt(start, "'_' must separate successive digits in number literal %s", literal)
+		return literal, token.ILLEGAL
+	case tokenType == token.INT && len(literal) > 1 && literal[0] == '0':
+		l.errorAt(start, "leading zeros are not allowed in decimal literal %s, use the 0o prefix for octal", literal)
+		return literal, token.ILLEGAL
+	}
+
+	return literal, tokenType
+}
+
+// readPrefixedInteger reads an integer literal with a base prefix such as 0x, starting at the current char. Any
+// hexadecimal digit is consumed so that a digit which is out of range for the base can be reported
+func (l *Lexer) readPrefixedInteger(start token.Position, base string, isBaseDigit func(rune) bool) (string, token.TokenType) {
+	position := l.position
+	l.readChar()
+	l.readDigits(isHexDigit)
+
+	literal := l.input[position:l.readPosition]
+	digits := literal[2:]
+
+	if len(digits) == 0 {
+		l.errorAt(start, "%s literal %s has no digits", base, literal)
+		return literal, token.ILLEGAL
+	}
+
+	for _, ch := range digits {
+		if ch != '_' && !isBaseDigit(ch) {
+			l.errorAt(start, "invalid digit %q in %s literal %s", ch, base, literal)
+			return literal, token.ILLEGAL
 		}
+	}
 
+	if !hasValidSeparators(digits, isBaseDigit) {
+		l.errorAt(start, "'_' must separate successive digits in number literal %s", literal)
+		return literal, token.ILLEGAL
+	}
+
+	return literal, token.INT
+}
+
+// readDigits reads the run of digits and '_' separators following the current char
+func (l *Lexer) readDigits(isDigit func(rune) bool) {
+	for isDigit(l.peekChar()) || l.peekChar() == '_' {
 		l.readChar()
 	}
+}
+
+// hasValidSeparators reports whether every '_' in literal sits between two digits
+func hasValidSeparators(literal string, isDigit func(rune) bool) bool {
+	for i := 0; i < len(literal); i++ {
+		if literal[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(literal)-1 || !isDigit(rune(literal[i-1])) || !isDigit(rune(literal[i+1])) {
+			return false
+		}
+	}
+	return true
+}
+
+// readComment reads a // line comment or a /* block comment */ starting at the current char, leaving the lexer on its
+// last char. Block comments may be nested. An unterminated block comment is returned as an ILLEGAL token.
+func (l *Lexer) readComment() token.Token {
+	position := l.position
+	start := l.pos()
+	l.readChar()
+
+	if l.ch == '/' {
+		for l.peekChar() != '\n' && l.peekChar() != 0 {
+			l.readChar()
+		}
+		return token.Token{Type: token.COMMENT, Literal: l.input[position:l.readPosition]}
+	}
+
+	depth := 1
+	for depth > 0 {
+		l.readChar()
+
+		switch {
+		case l.ch == 0:
+			l.errorAt(start, "unterminated block comment")
+			return token.Token{Type: token.ILLEGAL, Literal: l.input[position:l.position]}
+		case l.ch == '/' && l.peekChar() == '*':
+			l.readChar()
+			depth++
+		case l.ch == '*' && l.peekChar() == '/':
+			l.readChar()
+			depth--
+		}
+	}
 
-	return literal
+	return token.Token{Type: token.COMMENT, Literal: l.input[position:l.readPosition]}
+}
+
+// stringToken returns the token for a string literal which started at start and has been read up to its closing
+// quote. Strings containing errors are returned as ILLEGAL tokens holding the source of the literal.
+func (l *Lexer) stringToken(value string, ok bool, start token.Position) token.Token {
+	if !ok {
+		return token.Token{Type: token.ILLEGAL, Literal: l.input[start.Offset:l.readPosition]}
+	}
+	return token.Token{Type: token.STRING, Literal: value}
+}
+
+// readStringToken reads a double quoted string, or the part of one which follows an interpolated expression. The
+// token is of type closed when the string runs to its closing quote, or of type opened when it runs to the ${ which
+// begins an interpolated expression.
+func (l *Lexer) readStringToken(start token.Position, closed, opened token.TokenType) token.Token {
+	value, ok, interpolated := l.readString()
+
+	tok := l.stringToken(value, ok, start)
+	if tok.Type == token.ILLEGAL {
+		return tok
+	}
+
+	if interpolated {
+		l.interpolations = append(l.interpolations, 0)
+		tok.Type = opened
+	} else {
+		tok.Type = closed
+	}
+
+	return tok
+}
+
+// readString reads a double quoted string starting at the current char, decoding escape sequences. It leaves the
+// lexer on the closing quote, or on the { of a ${ which begins an interpolated expression. It reports whether the
+// string was valid and whether it ended at an interpolated expression. Strings may not span lines.
+func (l *Lexer) readString() (string, bool, bool) {
+	start := l.pos()
+	var out strings.Builder
+	valid := true
+
+	for {
+		if peek := l.peekChar(); peek == 0 || peek == '\n' {
+			l.errorAt(start, "unterminated string literal")
+			return out.String(), false, false
+		}
+
+		l.readChar()
+
+		switch {
+		case l.ch == '"':
+			return out.String(), valid, false
+		case l.ch == '$' && l.peekChar() == '{':
+			l.readChar()
+			return out.String(), valid, true
+		case l.ch == '\\':
+			valid = l.readEscape(&out) && valid
+		case l.isInvalidEncoding():
+			l.errorAt(l.pos(), "invalid UTF-8 encoding in string literal")
+			valid = false
+		default:
+			out.WriteRune(l.ch)
+		}
+	}
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// readRawString reads a backtick quoted string starting at the current char, leaving the lexer on the closing
+// backtick. Raw strings may span lines and do not interpret escape sequences.
+func (l *Lexer) readRawString() (string, bool) {
+	start := l.pos()
+	valid := true
+
 	for {
+		if l.peekChar() == 0 {
+			l.errorAt(start, "unterminated raw string literal")
+			return "", false
+		}
+
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+
+		switch {
+		case l.ch == '`':
+			return l.input[start.Offset+1 : l.position], valid
+		case l.isInvalidEncoding():
+			l.errorAt(l.pos(), "invalid UTF-8 encoding in string literal")
+			valid = false
 		}
 	}
-	return l.input[position:l.position]
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'$':  '$',
+}
+
+// readEscape decodes the escape sequence starting at the backslash under the current char into out, leaving the
+// lexer on the last char of the sequence. It reports whether the escape sequence was valid.
+func (l *Lexer) readEscape(out *strings.Builder) bool {
+	start := l.pos()
+
+	if peek := l.peekChar(); peek == 0 || peek == '\n' {
+		// leave the end of line to be reported as an unterminated string
+		return false
+	}
+
+	l.readChar()
+
+	if ch, ok := escapes[l.ch]; ok {
+		out.WriteRune(ch)
+		return true
+	}
+
+	if l.ch != 'u' {
+		l.errorAt(start, "invalid escape sequence \\%c", l.ch)
+		return false
+	}
+
+	if l.peekChar() != '{' {
+		l.errorAt(start, "invalid unicode escape sequence, expected the form \\u{1F600}")
+		return false
+	}
+	l.readChar()
+
+	digits := ""
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+		digits += string(l.ch)
+	}
+
+	if l.peekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+		l.errorAt(start, "invalid unicode escape sequence, expected the form \\u{1F600}")
+		return false
+	}
+	l.readChar()
+
+	code, _ := strconv.ParseUint(digits, 16, 32)
+	if !utf8.ValidRune(rune(code)) {
+		l.errorAt(start, "invalid unicode code point U+%s", strings.ToUpper(digits))
+		return false
+	}
+
+	out.WriteRune(rune(code))
+	return true
+}
+
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+// isInvalidEncoding reports whether the current char is a byte which is not valid utf-8
+func (l *Lexer) isInvalidEncoding() bool {
+	return l.ch == utf8.RuneError && l.readPosition-l.position == 1
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 1
+	} else if l.readPosition > l.position {
+		l.column++
+	}
+
+	l.position = l.readPosition
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
-	} else {
-		l.ch = l.input[l.readPosition]
+		return
 	}
 
-	l.position = l.readPosition
-	l.readPosition++
+	// invalid utf-8 is decoded as utf8.RuneError, one byte at a time
+	ch, width := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	l.ch = ch
+	l.readPosition += width
+}
+
+// peekNthChar returns the char n chars after the current char, peekNthChar(1) being the same as peekChar()
+func (l *Lexer) peekNthChar(n int) rune {
+	position := l.readPosition
+	for i := 1; i < n && position < len(l.input); i++ {
+		_, width := utf8.DecodeRuneInString(l.input[position:])
+		position += width
+	}
+
+	if position >= len(l.input) {
+		return 0
+	}
+
+	ch, _ := utf8.DecodeRuneInString(l.input[position:])
+	return ch
 }
 
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.readPosition]
+		ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+		return ch
 	}
 }
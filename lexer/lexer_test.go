@@ -15,7 +15,7 @@ let add = fn(x, y) {
 };
 
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 5;
 
 if (5 < 10) {
@@ -194,3 +194,422 @@ func TestStringSubstitutionToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  x == "ab";`
+
+	tests := []struct {
+		expectedType  token.TokenType
+		expectedStart token.Position
+		expectedEnd   token.Position
+	}{
+		{token.LET, token.Position{Filename: "test.mk", Offset: 0, Line: 1, Column: 1}, token.Position{Filename: "test.mk", Offset: 3, Line: 1, Column: 4}},
+		{token.IDENT, token.Position{Filename: "test.mk", Offset: 4, Line: 1, Column: 5}, token.Position{Filename: "test.mk", Offset: 5, Line: 1, Column: 6}},
+		{token.ASSIGN, token.Position{Filename: "test.mk", Offset: 6, Line: 1, Column: 7}, token.Position{Filename: "test.mk", Offset: 7, Line: 1, Column: 8}},
+		{token.INT, token.Position{Filename: "test.mk", Offset: 8, Line: 1, Column: 9}, token.Position{Filename: "test.mk", Offset: 9, Line: 1, Column: 10}},
+		{token.SEMICOLON, token.Position{Filename: "test.mk", Offset: 9, Line: 1, Column: 10}, token.Position{Filename: "test.mk", Offset: 10, Line: 1, Column: 11}},
+		{token.IDENT, token.Position{Filename: "test.mk", Offset: 13, Line: 2, Column: 3}, token.Position{Filename: "test.mk", Offset: 14, Line: 2, Column: 4}},
+		{token.EQ, token.Position{Filename: "test.mk", Offset: 15, Line: 2, Column: 5}, token.Position{Filename: "test.mk", Offset: 17, Line: 2, Column: 7}},
+		{token.STRING, token.Position{Filename: "test.mk", Offset: 18, Line: 2, Column: 8}, token.Position{Filename: "test.mk", Offset: 22, Line: 2, Column: 12}},
+		{token.SEMICOLON, token.Position{Filename: "test.mk", Offset: 22, Line: 2, Column: 12}, token.Position{Filename: "test.mk", Offset: 23, Line: 2, Column: 13}},
+		{token.EOF, token.Position{Filename: "test.mk", Offset: 23, Line: 2, Column: 13}, token.Position{Filename: "test.mk", Offset: 23, Line: 2, Column: 13}},
+	}
+
+	l := New(input, WithFilename("test.mk"))
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos != tt.expectedStart {
+			t.Errorf("tests[%d] - start position wrong. expected=%+v, got=%+v", i, tt.expectedStart, tok.Pos)
+		}
+
+		if tok.End != tt.expectedEnd {
+			t.Errorf("tests[%d] - end position wrong. expected=%+v, got=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestDenseCode(t *testing.T) {
+	tests := []struct {
+		dense  string
+		spaced string
+	}{
+		{"x+1", "x + 1"},
+		{"a==b", "a == b"},
+		{"a!=b", "a != b"},
+		{"!x", "! x"},
+		{"!!x", "! ! x"},
+		{"-x*y/z", "- x * y / z"},
+		{"a<b>c", "a < b > c"},
+		{"let x=5;", "let x = 5 ;"},
+		{"let add=fn(x,y){x+y;};", "let add = fn ( x , y ) { x + y ; } ;"},
+		{"add(1,2*3)", "add ( 1 , 2 * 3 )"},
+		{"if(x<10){return true;}else{return false;}", "if ( x < 10 ) { return true ; } else { return false ; }"},
+		{`{"a":1,"b":x}["a"]`, `{ "a" : 1 , "b" : x } [ "a" ]`},
+		{"[1,2][0]", "[ 1 , 2 ] [ 0 ]"},
+		{"foo_bar1+_baz2", "foo_bar1 + _baz2"},
+		{"10==10!=false", "10 == 10 != false"},
+		{"a&&b||!c", "a && b || ! c"},
+		{"a<=b>=c", "a <= b >= c"},
+		{"fn(a,...b){f(...b)}", "fn ( a , ... b ) { f ( ... b ) }"},
+		{"a+=1;b-=2;c*=3;d/=4;e==f", "a += 1 ; b -= 2 ; c *= 3 ; d /= 4 ; e == f"},
+		{"a%b**c&d|e^~f<<g>>h", "a % b ** c & d | e ^ ~ f << g >> h"},
+		{"match(x){1=>a,_=>b==c}", "match ( x ) { 1 => a , _ => b == c }"},
+		{"a?b:c", "a ? b : c"},
+		{"a?.[b]??c", "a ?. [ b ] ?? c"},
+	}
+
+	for _, tt := range tests {
+		dense := New(tt.dense)
+		spaced := New(tt.spaced)
+
+		for i := 0; ; i++ {
+			expected := spaced.NextToken()
+			got := dense.NextToken()
+
+			if got.Type != expected.Type || got.Literal != expected.Literal {
+				t.Errorf("%q token[%d] wrong. expected=%s %q, got=%s %q", tt.dense, i, expected.Type, expected.Literal, got.Type, got.Literal)
+				break
+			}
+
+			if expected.Type == token.EOF {
+				break
+			}
+		}
+	}
+}
+
+func TestCharacterClasses(t *testing.T) {
+	input := `five5 5five @`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "five5"},
+		{token.INT, "5"},
+		{token.IDENT, "five"},
+		{token.ILLEGAL, "@"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := `5 3.14 0.5 1e9 2.5E-3 7e+2 1. 1.x 3e 3e+`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "0.5"},
+		{token.FLOAT, "1e9"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "7e+2"},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.INT, "1"},
+		{token.ILLEGAL, "."},
+		{token.IDENT, "x"},
+		{token.INT, "3"},
+		{token.IDENT, "e"},
+		{token.INT, "3"},
+		{token.IDENT, "e"},
+		{token.PLUS, "+"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestIntegerLiterals(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedError   string
+	}{
+		{"0xFF", token.INT, "0xFF", ""},
+		{"0X1f", token.INT, "0X1f", ""},
+		{"0o17", token.INT, "0o17", ""},
+		{"0b1010", token.INT, "0b1010", ""},
+		{"1_000_000", token.INT, "1_000_000", ""},
+		{"0b1111_0000", token.INT, "0b1111_0000", ""},
+		{"1_000.5e1_0", token.FLOAT, "1_000.5e1_0", ""},
+		{"0", token.INT, "0", ""},
+		{"0x", token.ILLEGAL, "0x", "1:1: error: hexadecimal literal 0x has no digits"},
+		{"0b102", token.ILLEGAL, "0b102", "1:1: error: invalid digit '2' in binary literal 0b102"},
+		{"0o78", token.ILLEGAL, "0o78", "1:1: error: invalid digit '8' in octal literal 0o78"},
+		{"1__000", token.ILLEGAL, "1__000", "1:1: error: '_' must separate successive digits in number literal 1__000"},
+		{"100_", token.ILLEGAL, "100_", "1:1: error: '_' must separate successive digits in number literal 100_"},
+		{"0x_FF", token.ILLEGAL, "0x_FF", "1:1: error: '_' must separate successive digits in number literal 0x_FF"},
+		{"0755", token.ILLEGAL, "0755", "1:1: error: leading zeros are not allowed in decimal literal 0755, use the 0o prefix for octal"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%q - tokentype wrong. expected=%q, got=%q", tt.input, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%q - literal wrong. expected=%q, got=%q", tt.input, tt.expectedLiteral, tok.Literal)
+		}
+
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("%q - expected the whole input to be consumed, got %q", tt.input, next.Literal)
+		}
+
+		diagnostics := l.Diagnostics()
+		if tt.expectedError == "" {
+			if len(diagnostics) != 0 {
+				t.Errorf("%q - unexpected diagnostics %v", tt.input, diagnostics)
+			}
+			continue
+		}
+
+		if len(diagnostics) != 1 || diagnostics[0].String() != tt.expectedError {
+			t.Errorf("%q - wrong diagnostics. expected=%q, got=%v", tt.input, tt.expectedError, diagnostics)
+		}
+	}
+}
+
+func TestUnicode(t *testing.T) {
+	input := "let größe = \"héllo 🙈\";\nπ+ñ1 \xff"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "größe", 5},
+		{token.ASSIGN, "=", 11},
+		{token.STRING, "héllo 🙈", 13},
+		{token.SEMICOLON, ";", 22},
+		{token.IDENT, "π", 1},
+		{token.PLUS, "+", 2},
+		{token.IDENT, "ñ1", 3},
+		{token.ILLEGAL, "\xff", 6},
+		{token.EOF, "", 7},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - column wrong. expected=%d, got=%d", i, tt.expectedColumn, tok.Pos.Column)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading comment
+let x = 5; // trailing comment
+/* block /* nested */ still comment */ x / 2;
+/* unterminated`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.COMMENT, "// leading comment"},
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.COMMENT, "// trailing comment"},
+		{token.COMMENT, "/* block /* nested */ still comment */"},
+		{token.IDENT, "x"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.ILLEGAL, "/* unterminated"},
+		{token.EOF, ""},
+	}
+
+	emitting := New(input, WithComments())
+	skipping := New(input)
+
+	for i, tt := range tests {
+		tok := emitting.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tt.expectedType == token.COMMENT {
+			continue
+		}
+
+		tok = skipping.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - comment not skipped. expected=%s %q, got=%s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedType       token.TokenType
+		expectedLiteral    string
+		expectedDiagnostic string
+	}{
+		{`"a\nb"`, token.STRING, "a\nb", ""},
+		{`"tab\there"`, token.STRING, "tab\there", ""},
+		{`"back\\slash"`, token.STRING, `back\slash`, ""},
+		{`"say \"hi\""`, token.STRING, `say "hi"`, ""},
+		{`"cr\r"`, token.STRING, "cr\r", ""},
+		{`"\u{1F600}\u{e9}"`, token.STRING, "😀é", ""},
+		{"`raw \\n\n\"string\"`", token.STRING, "raw \\n\n\"string\"", ""},
+		{`"bad \q"`, token.ILLEGAL, `"bad \q"`, `invalid escape sequence \q`},
+		{`"\u1F600"`, token.ILLEGAL, `"\u1F600"`, `invalid unicode escape sequence, expected the form \u{1F600}`},
+		{`"\u{}"`, token.ILLEGAL, `"\u{}"`, `invalid unicode escape sequence, expected the form \u{1F600}`},
+		{`"\u{D800}"`, token.ILLEGAL, `"\u{D800}"`, `invalid unicode code point U+D800`},
+		{`"\u{110000}"`, token.ILLEGAL, `"\u{110000}"`, `invalid unicode code point U+110000`},
+		{`"unterminated`, token.ILLEGAL, `"unterminated`, "unterminated string literal"},
+		{"\"line\nbreak\"", token.ILLEGAL, `"line`, "unterminated string literal"},
+		{"`unterminated", token.ILLEGAL, "`unterminated", "unterminated raw string literal"},
+		{"\"\xff\"", token.ILLEGAL, "\"\xff\"", "invalid UTF-8 encoding in string literal"},
+		{"@", token.ILLEGAL, "@", `illegal character "@"`},
+		{"/* open", token.ILLEGAL, "/* open", "unterminated block comment"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Errorf("%q - tokentype wrong. expected=%q, got=%q", tt.input, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%q - literal wrong. expected=%q, got=%q", tt.input, tt.expectedLiteral, tok.Literal)
+		}
+
+		diagnostics := l.Diagnostics()
+		if tt.expectedDiagnostic == "" {
+			if len(diagnostics) != 0 {
+				t.Errorf("%q - unexpected diagnostics %v", tt.input, diagnostics)
+			}
+			continue
+		}
+
+		if len(diagnostics) != 1 {
+			t.Errorf("%q - expected 1 diagnostic, got %d", tt.input, len(diagnostics))
+			continue
+		}
+
+		if diagnostics[0].Message != tt.expectedDiagnostic {
+			t.Errorf("%q - diagnostic wrong. expected=%q, got=%q", tt.input, tt.expectedDiagnostic, diagnostics[0].Message)
+		}
+	}
+}
+
+func TestStringDiagnosticPosition(t *testing.T) {
+	l := New(`let s = "ab\qc";`)
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+	}
+
+	diagnostics := l.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %d", len(diagnostics))
+	}
+
+	if diagnostics[0].Pos.String() != "1:12" || diagnostics[0].End.String() != "1:14" {
+		t.Errorf("diagnostic span wrong. expected=1:12-1:14, got=%s-%s", diagnostics[0].Pos, diagnostics[0].End)
+	}
+}
+
+func TestStringInterpolation(t *testing.T) {
+	input := `"total: ${a + {"k": 1}["k"]} of ${"n=${n}"}!" "\${literal}"`
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.STRING_HEAD, "total: "},
+		{token.IDENT, "a"},
+		{token.PLUS, "+"},
+		{token.LBRACE, "{"},
+		{token.STRING, "k"},
+		{token.COLON, ":"},
+		{token.INT, "1"},
+		{token.RBRACE, "}"},
+		{token.LBRACKET, "["},
+		{token.STRING, "k"},
+		{token.RBRACKET, "]"},
+		{token.STRING_MIDDLE, " of "},
+		{token.STRING_HEAD, "n="},
+		{token.IDENT, "n"},
+		{token.STRING_TAIL, ""},
+		{token.STRING_TAIL, "!"},
+		{token.STRING, "${literal}"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
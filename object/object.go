@@ -7,7 +7,12 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
 	"monkey-interpreter/ast"
+	"monkey-interpreter/token"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -15,10 +20,15 @@ type ObjectType string
 
 const (
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	INTEGER_OBJ      = "INTEGER"
+	BIGINT_OBJ       = "BIGINT"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	ERROR_OBJ        = "ERROR"
+	ERROR_VALUE_OBJ  = "ERROR_VALUE"
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
 	BUILTIN_OBJ      = "BUILTIN"
@@ -64,6 +74,19 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// Assign updates the nearest binding of name in this or an enclosing environment, reporting false if name is not bound
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+
+	if e.enclosingEnvironment != nil {
+		return e.enclosingEnvironment.Assign(name, val)
+	}
+	return false
+}
+
 type ReturnValue struct {
 	Value Object
 }
@@ -71,6 +94,18 @@ type ReturnValue struct {
 func (r *ReturnValue) Inspect() string  { return r.Value.Inspect() }
 func (r *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 
+// Break and Continue signal that a break or continue statement was evaluated, unwinding to the innermost loop the same
+// way a ReturnValue unwinds to the function call
+type Break struct{}
+
+func (b *Break) Inspect() string  { return "break" }
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+
+type Continue struct{}
+
+func (c *Continue) Inspect() string  { return "continue" }
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+
 type Integer struct {
 	Value int64
 }
@@ -78,6 +113,43 @@ type Integer struct {
 func (i *Integer) Inspect() string  { return fmt.Sprintf("%d", i.Value) }
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+// InspectBase formats the integer in the given base, which must be between 2 and 36
+func (i *Integer) InspectBase(base int) string { return strconv.FormatInt(i.Value, base) }
+
+// BigInt is an integer which does not fit in an int64. Use IntegerFromBig to create one so that values which do fit
+// are always represented by an Integer
+type BigInt struct {
+	Value *big.Int
+}
+
+func (b *BigInt) Inspect() string  { return b.Value.String() }
+func (b *BigInt) Type() ObjectType { return BIGINT_OBJ }
+
+// InspectBase formats the integer in the given base, which must be between 2 and 36
+func (b *BigInt) InspectBase(base int) string { return b.Value.Text(base) }
+
+// IntegerFromBig returns an Integer when v fits in an int64 and a BigInt otherwise
+func IntegerFromBig(v *big.Int) Object {
+	if v.IsInt64() {
+		return &Integer{Value: v.Int64()}
+	}
+	return &BigInt{Value: v}
+}
+
+type Float struct {
+	Value float64
+}
+
+// Inspect formats the float so that it is always distinguishable from an integer e.g. 3.0 rather than 3
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eInN") {
+		s += ".0"
+	}
+	return s
+}
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
 type Boolean struct {
 	Value bool
 }
@@ -90,17 +162,43 @@ type Null struct{}
 func (n *Null) Inspect() string  { return "null" }
 func (n *Null) Type() ObjectType { return NULL_OBJ }
 
+// Kinds of Error
+const (
+	RUNTIME_ERROR = "runtime" // raised by the interpreter e.g. division by zero
+	USER_ERROR    = "error"   // created by a script, with the error builtin or by throwing a value
+)
+
+// Error is a runtime error. Pos and End span the source which raised it, they are not valid for errors which did not
+// come from evaluating source. Data is the value attached to an error created by a script, or nil. A fatal error is
+// a failure of the interpreter itself rather than of the script, which cannot be caught
 type Error struct {
 	Message string
+	Kind    string
+	Data    Object
+	Fatal   bool
+	Pos     token.Position
+	End     token.Position
 }
 
 func (e *Error) Inspect() string  { return e.Message }
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 
+// ErrorValue holds an error as an ordinary value, such as the error bound by a catch clause or the result of the
+// error builtin. Unlike an Error it does not stop evaluation until it is thrown
+type ErrorValue struct {
+	Error *Error
+}
+
+func (ev *ErrorValue) Inspect() string  { return "error: " + ev.Error.Message }
+func (ev *ErrorValue) Type() ObjectType { return ERROR_VALUE_OBJ }
+
 type Function struct {
 	Parameters []*ast.Identifier
+	Defaults   map[string]ast.Expression
+	Rest       *ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Name       string
 }
 
 func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
@@ -109,7 +207,14 @@ func (f *Function) Inspect() string {
 
 	params := []string{}
 	for _, p := range f.Parameters {
-		params = append(params, p.String())
+		if value, ok := f.Defaults[p.Value]; ok {
+			params = append(params, p.String()+" = "+value.String())
+		} else {
+			params = append(params, p.String())
+		}
+	}
+	if f.Rest != nil {
+		params = append(params, "..."+f.Rest.String())
 	}
 
 	out.WriteString("fn")
@@ -141,18 +246,48 @@ type Array struct {
 }
 
 func (a *Array) Type() ObjectType { return ARRAY_OBJ }
-func (a *Array) Inspect() string {
+func (a *Array) Inspect() string  { return inspect(a, map[Object]bool{}) }
+
+// inspect formats obj, where seen holds the arrays and hashes which contain it. Arrays and hashes are mutable so they
+// can contain themselves, an array or hash which is already being formatted is written as [...] or {...}
+func inspect(obj Object, seen map[Object]bool) string {
 	var out bytes.Buffer
 
-	elements := []string{}
-	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
+	switch obj := obj.(type) {
+	case *Array:
+		if seen[obj] {
+			return "[...]"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, seen))
+		}
+
+		out.WriteString("[")
+		out.WriteString(strings.Join(elements, ", "))
+		out.WriteString("]")
+	case *Hash:
+		if seen[obj] {
+			return "{...}"
+		}
+		seen[obj] = true
+		defer delete(seen, obj)
+
+		pairs := []string{}
+		for _, pair := range obj.SortedPairs() {
+			pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), inspect(pair.Value, seen)))
+		}
+
+		out.WriteString("{")
+		out.WriteString(strings.Join(pairs, ", "))
+		out.WriteString("}")
+	default:
+		return obj.Inspect()
 	}
 
-	out.WriteString("[")
-	out.WriteString(strings.Join(elements, ", "))
-	out.WriteString("]")
-
 	return out.String()
 }
 
@@ -177,6 +312,27 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(b.Value.Bytes())
+	if b.Value.Sign() < 0 {
+		h.Write([]byte{'-'})
+	}
+
+	return HashKey{Type: b.Type(), Value: h.Sum64()}
+}
+
+// HashKey of a float agrees with ==, so -0.0 has the same key as 0.0 and a float with an integral value has the
+// same key as the equal integer
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && !math.IsInf(f.Value, 0) {
+		integer, _ := big.NewFloat(f.Value).Int(nil)
+		return IntegerFromBig(integer).(Hashable).HashKey()
+	}
+
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -193,22 +349,45 @@ type Hash struct {
 	Pairs map[HashKey]HashPair
 }
 
-func (h *Hash) Inspect() string {
-	var out bytes.Buffer
+func (h *Hash) Inspect() string { return inspect(h, map[Object]bool{}) }
 
-	pairs := []string{}
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+
+// SortedPairs returns the pairs of the hash in a deterministic order. Keys are grouped by type, and keys of the same
+// type are in ascending order
+func (h *Hash) SortedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
 	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+		pairs = append(pairs, pair)
 	}
 
-	out.WriteString("{")
-	out.WriteString(strings.Join(pairs, ", "))
-	out.WriteString("}")
+	sort.Slice(pairs, func(i, j int) bool {
+		return keyLess(pairs[i].Key, pairs[j].Key)
+	})
 
-	return out.String()
+	return pairs
 }
 
-func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func keyLess(a, b Object) bool {
+	if a.Type() != b.Type() {
+		return a.Type() < b.Type()
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value < b.(*Integer).Value
+	case *BigInt:
+		return a.Value.Cmp(b.(*BigInt).Value) < 0
+	case *Float:
+		return a.Value < b.(*Float).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	}
+
+	return false
+}
 
 type Hashable interface {
 	HashKey() HashKey
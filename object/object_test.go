@@ -1,6 +1,10 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -58,3 +62,123 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with different content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	float1 := &Float{Value: 1.5}
+	float2 := &Float{Value: 1.5}
+	diff := &Float{Value: 2.5}
+
+	if float1.HashKey() != float2.HashKey() {
+		t.Errorf("floats with same content have different hash keys")
+	}
+
+	if float1.HashKey() == diff.HashKey() {
+		t.Errorf("floats with different content have same hash keys")
+	}
+
+	if float1.HashKey() == (&Integer{Value: 1}).HashKey() {
+		t.Errorf("float and integer have same hash keys")
+	}
+
+	// floats which are == to each other or to an integer have the same hash key
+	if (&Float{Value: math.Copysign(0, -1)}).HashKey() != (&Float{Value: 0}).HashKey() {
+		t.Errorf("-0.0 and 0.0 have different hash keys")
+	}
+
+	if (&Float{Value: 1}).HashKey() != (&Integer{Value: 1}).HashKey() {
+		t.Errorf("1.0 and 1 have different hash keys")
+	}
+
+	if (&Float{Value: 0x1p70}).HashKey() != (&BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 70)}).HashKey() {
+		t.Errorf("2^70 as a float and a big integer have different hash keys")
+	}
+}
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3, "3.0"},
+		{3.14, "3.14"},
+		{-0.5, "-0.5"},
+		{1e21, "1e+21"},
+	}
+
+	for _, tt := range tests {
+		if got := (&Float{Value: tt.value}).Inspect(); got != tt.expected {
+			t.Errorf("wrong inspect for %g. expected=%q, got=%q", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestBigIntHashKey(t *testing.T) {
+	big1 := &BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 70)}
+	big2 := &BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 70)}
+	negative := &BigInt{Value: new(big.Int).Neg(big1.Value)}
+
+	if big1.HashKey() != big2.HashKey() {
+		t.Errorf("big integers with same content have different hash keys")
+	}
+
+	if big1.HashKey() == negative.HashKey() {
+		t.Errorf("big integers with different signs have same hash keys")
+	}
+}
+
+func TestIntegerFromBig(t *testing.T) {
+	if _, ok := IntegerFromBig(big.NewInt(42)).(*Integer); !ok {
+		t.Errorf("expected a value which fits in an int64 to be an Integer")
+	}
+
+	if _, ok := IntegerFromBig(new(big.Int).Lsh(big.NewInt(1), 63)).(*BigInt); !ok {
+		t.Errorf("expected a value which overflows an int64 to be a BigInt")
+	}
+}
+
+func TestHashSortedPairs(t *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	keys := []Object{
+		&String{Value: "b"},
+		&Integer{Value: 2},
+		&String{Value: "a"},
+		&Boolean{Value: true},
+		&Integer{Value: -1},
+		&Boolean{Value: false},
+	}
+	for _, key := range keys {
+		hash.Pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: key}
+	}
+
+	expected := "{false: false, true: true, -1: -1, 2: 2, a: a, b: b}"
+	for i := 0; i < 10; i++ {
+		if hash.Inspect() != expected {
+			t.Fatalf("wrong hash inspect. expected=%q, got=%q", expected, hash.Inspect())
+		}
+	}
+}
+
+func TestInspectSelfReferencingValues(t *testing.T) {
+	array := &Array{Elements: []Object{&Integer{Value: 1}}}
+	array.Elements = append(array.Elements, array)
+
+	if array.Inspect() != "[1, [...]]" {
+		t.Errorf("wrong array inspect. got=%q", array.Inspect())
+	}
+
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	key := &String{Value: "self"}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: &Array{Elements: []Object{hash}}}
+
+	if hash.Inspect() != "{self: [{...}]}" {
+		t.Errorf("wrong hash inspect. got=%q", hash.Inspect())
+	}
+
+	// a value referenced twice without containing itself is written in full each time
+	shared := &Array{Elements: []Object{&Integer{Value: 2}}}
+	outer := &Array{Elements: []Object{shared, shared}}
+
+	if outer.Inspect() != "[[2], [2]]" {
+		t.Errorf("wrong shared inspect. got=%q", outer.Inspect())
+	}
+}
@@ -6,10 +6,14 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"monkey-interpreter/ast"
+	"monkey-interpreter/diagnostic"
 	"monkey-interpreter/lexer"
 	"monkey-interpreter/token"
+	"sort"
 	"strconv"
 )
 
@@ -17,27 +21,56 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // = += -= *= /=
+	CONDITIONAL // ?:
+	NULLISH     // ??
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
-	LESSGREATER // > or <
+	LESSGREATER // > < >= <=
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
+	SHIFT       // << >>
 	SUM         // + -
-	PRODUCT     // *
-	PREFIX      // -X or !X
+	PRODUCT     // * / %
+	PREFIX      // -X or !X or ~X
+	POWER       // ** binds tighter than a prefix operator so -2 ** 2 is -(2 ** 2)
 	CALL        // function(X)
 	INDEX       // array[index]
 )
 
 // token precendence
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
-	token.LBRACKET: INDEX,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.QUESTION:        CONDITIONAL,
+	token.NULLISH:         NULLISH,
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.PIPE:            BIT_OR,
+	token.CARET:           BIT_XOR,
+	token.AMPERSAND:       BIT_AND,
+	token.SHIFT_LEFT:      SHIFT,
+	token.SHIFT_RIGHT:     SHIFT,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,
+	token.LBRACKET:        INDEX,
+	token.OPTIONAL:        INDEX,
 }
 
 type (
@@ -47,17 +80,26 @@ type (
 
 type Parser struct {
 	l              *lexer.Lexer
+	prevToken      token.Token
 	curToken       token.Token
 	peekToken      token.Token
-	errors         []string
+	pending        []token.Token // tokens returned by backup, to be read again before lexing more input
+	stmtStart      token.Token   // the first token of the statement being parsed
+	loopDepth      int           // number of loops enclosing the current token, within the current function
+	nesting        int           // number of delimiters opened and not yet closed, up to and including the current token
+	comments       []*ast.Comment
+	lexed          int                             // number of lexer diagnostics seen so far
+	lexDiagnostics map[int][]diagnostic.Diagnostic // lexer diagnostics held back until their token is current, by offset
+	diagnostics    []diagnostic.Diagnostic
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
 
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:              l,
+		diagnostics:    []diagnostic.Diagnostic{},
+		lexDiagnostics: make(map[int][]diagnostic.Diagnostic),
 	}
 
 	// read two tokens so curToken and peekToken are populated
@@ -65,16 +107,25 @@ func New(l *lexer.Lexer) *Parser {
 	p.nextToken()
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
+	p.registerPrefix(token.ILLEGAL, p.parseIllegal)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.TILDE, p.parsePrefixExpression)
+	p.registerPrefix(token.ELLIPSIS, p.parseMisplacedSpread)
 	p.registerPrefix(token.TRUE, p.parseBooleanExpression)
 	p.registerPrefix(token.FALSE, p.parseBooleanExpression)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.FOR, p.parseForInExpression)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.NULL, p.parseNull)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 	p.registerPrefix(token.STRING, p.parseStringExpression)
+	p.registerPrefix(token.STRING_HEAD, p.parseInterpolatedString)
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
@@ -82,19 +133,86 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.AMPERSAND, p.parseInfixExpression)
+	p.registerInfix(token.PIPE, p.parseInfixExpression)
+	p.registerInfix(token.CARET, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.NULLISH, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.QUESTION, p.parseConditionalExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.OPTIONAL, p.parseOptionalIndexExpression)
 
 	return p
 }
 
 func (p *Parser) nextToken() {
+	p.prevToken = p.curToken
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+
+	switch {
+	case openingDelimiters[p.curToken.Type]:
+		p.nesting++
+	case closingDelimiters[p.curToken.Type]:
+		p.nesting--
+	}
+
+	// report problems found by the lexer as part of the statement containing the token they belong to
+	if diagnostics, ok := p.lexDiagnostics[p.curToken.Pos.Offset]; ok {
+		p.diagnostics = append(p.diagnostics, diagnostics...)
+		delete(p.lexDiagnostics, p.curToken.Pos.Offset)
+	}
+
+	if len(p.pending) > 0 {
+		p.peekToken = p.pending[len(p.pending)-1]
+		p.pending = p.pending[:len(p.pending)-1]
+	} else {
+		p.peekToken = p.l.NextToken()
+
+		for p.peekToken.Type == token.COMMENT {
+			p.comments = append(p.comments, &ast.Comment{Token: p.peekToken})
+			p.peekToken = p.l.NextToken()
+		}
+
+		if lexed := p.l.Diagnostics(); len(lexed) > p.lexed {
+			p.lexDiagnostics[p.peekToken.Pos.Offset] = lexed[p.lexed:]
+			p.lexed = len(lexed)
+		}
+	}
+}
+
+// backup moves back a single token, so the current token will be read again by the next call to nextToken
+func (p *Parser) backup() {
+	switch {
+	case openingDelimiters[p.curToken.Type]:
+		p.nesting--
+	case closingDelimiters[p.curToken.Type]:
+		p.nesting++
+	}
+
+	p.pending = append(p.pending, p.peekToken)
+	p.peekToken = p.curToken
+	p.curToken = p.prevToken
+}
+
+func (p *Parser) curTokenIs(t token.TokenType) bool {
+	return p.curToken.Type == t
 }
 
 func (p *Parser) peekTokenIs(t token.TokenType) bool {
@@ -107,17 +225,59 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 		p.nextToken()
 		return true
 	} else {
+		p.peekError(t)
 		return false
 	}
 }
 
+// Diagnostics returns the problems found while parsing, in the order they were found.
+func (p *Parser) Diagnostics() []diagnostic.Diagnostic {
+	return p.diagnostics
+}
+
+// Errors returns the problems found while parsing formatted as strings.
 func (p *Parser) Errors() []string {
-	return p.errors
+	messages := []string{}
+	for _, d := range p.diagnostics {
+		messages = append(messages, d.String())
+	}
+	return messages
+}
+
+// errorAt records an error diagnostic spanning the given token. Only the first error at any position is recorded, as
+// later ones are almost always a consequence of the first.
+func (p *Parser) errorAt(tok token.Token, format string, a ...interface{}) {
+	if len(p.diagnostics) > 0 && p.diagnostics[len(p.diagnostics)-1].Pos == tok.Pos {
+		return
+	}
+
+	p.diagnostics = append(p.diagnostics, diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Message:  fmt.Sprintf(format, a...),
+		Pos:      tok.Pos,
+		End:      tok.End,
+		Found:    tok,
+	})
+}
+
+// addHint attaches a suggestion for fixing the problem to the most recent diagnostic
+func (p *Parser) addHint(hint string) {
+	if len(p.diagnostics) > 0 {
+		p.diagnostics[len(p.diagnostics)-1].Hint = hint
+	}
+}
+
+func (p *Parser) peekError(t token.TokenType) {
+	before := len(p.diagnostics)
+	p.errorAt(p.peekToken, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
+
+	if len(p.diagnostics) > before {
+		p.diagnostics[len(p.diagnostics)-1].Expected = []token.TokenType{t}
+	}
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found.", t)
-	p.errors = append(p.errors, msg)
+	p.errorAt(p.curToken, "no prefix parse function for %s found.", t)
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -131,39 +291,139 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+
+	// diagnostics from the lexer and parser are found in slightly different orders, present them in source order
+	sort.SliceStable(p.diagnostics, func(i, j int) bool {
+		return p.diagnostics[i].Pos.Offset < p.diagnostics[j].Pos.Offset
+	})
+
+	program.Comments = p.comments
 	return program
 }
 
+// parseStatement parses a single statement. If the statement contains syntax errors the tokens following the error
+// are skipped until the end of the statement, so that parsing can carry on from a known good state.
 func (p *Parser) parseStatement() ast.Statement {
+	p.stmtStart = p.curToken
+	before := len(p.diagnostics)
+
+	var stmt ast.Statement
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		stmt = p.parseLetStatement()
 	case token.RETURN:
-		return p.parseReturnStatement()
+		stmt = p.parseReturnStatement()
+	case token.WHILE:
+		stmt = p.parseWhileStatement()
+	case token.BREAK, token.CONTINUE:
+		stmt = p.parseLoopControlStatement()
+	case token.THROW:
+		stmt = p.parseThrowStatement()
 	default:
-		return p.parseExpressionStatement()
+		stmt = p.parseExpressionStatement()
+	}
+
+	if len(p.diagnostics) > before {
+		p.synchronize(token.SEMICOLON)
 	}
+
+	return stmt
 }
 
-func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+// synchronize skips the remaining tokens of a malformed construct. It stops once the current token is the given
+// closing token, or when the next token can only begin a new statement or close an enclosing block. Delimiters
+// belonging to nested constructs are skipped in pairs.
+func (p *Parser) synchronize(closing token.TokenType) {
+	depth := 0
+	for !p.curTokenIs(token.EOF) {
+		switch {
+		case depth == 0 && p.curTokenIs(closing):
+			return
+		case openingDelimiters[p.curToken.Type]:
+			depth++
+		case closingDelimiters[p.curToken.Type] && depth > 0:
+			depth--
+		}
 
-	if !p.expectPeek(token.IDENT) {
-		p.errors = append(p.errors, "unexpected token, expected IDENT")
-		return nil
+		if depth == 0 && p.peekTokenIs(closing) {
+			p.nextToken()
+			return
+		}
+
+		if depth == 0 && statementBoundaries[p.peekToken.Type] {
+			return
+		}
+
+		p.nextToken()
 	}
+}
+
+var openingDelimiters = map[token.TokenType]bool{
+	token.LPAREN:      true,
+	token.LBRACKET:    true,
+	token.LBRACE:      true,
+	token.STRING_HEAD: true,
+}
+
+var closingDelimiters = map[token.TokenType]bool{
+	token.RPAREN:      true,
+	token.RBRACKET:    true,
+	token.RBRACE:      true,
+	token.STRING_TAIL: true,
+}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+// tokens which can only appear at the start or end of a statement
+var statementBoundaries = map[token.TokenType]bool{
+	token.SEMICOLON: true,
+	token.RBRACE:    true,
+	token.EOF:       true,
+	token.LET:       true,
+	token.RETURN:    true,
+	token.WHILE:     true,
+	token.BREAK:     true,
+	token.CONTINUE:  true,
+	token.THROW:     true,
+}
+
+// badExpression returns a placeholder for an expression from start up to and including the current token
+func (p *Parser) badExpression(start token.Token) ast.Expression {
+	return &ast.BadExpression{Token: start, Last: p.curToken}
+}
+
+func (p *Parser) parseLetStatement() ast.Statement {
+	stmt := &ast.LetStatement{Token: p.curToken}
+
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parsePattern()
+		if stmt.Pattern == nil {
+			// a pattern cannot contain = or ; so the rest of a malformed one can be skipped without tracking its braces
+			for !p.peekTokenIs(token.ASSIGN) && !p.peekTokenIs(token.SEMICOLON) && !p.peekTokenIs(token.EOF) {
+				p.nextToken()
+			}
+			return &ast.BadStatement{Token: stmt.Token, Last: p.curToken}
+		}
+	} else if !p.expectPeek(token.IDENT) {
+		p.addHint("a let statement takes the form `let <identifier> = <expression>;`")
+		return &ast.BadStatement{Token: stmt.Token, Last: p.curToken}
+	} else {
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
 
 	if !p.expectPeek(token.ASSIGN) {
-		p.errors = append(p.errors, "unexpected token, expected ASSIGN")
-		return nil
+		p.addHint("a let statement takes the form `let <identifier> = <expression>;`")
+		return &ast.BadStatement{Token: stmt.Token, Last: p.curToken}
 	}
 
 	p.nextToken()
 
 	stmt.Value = p.parseExpression(LOWEST)
 
+	// name the function so that errors raised when calling it can refer to it
+	if function, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
+		function.Name = stmt.Name.Value
+	}
+
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -171,6 +431,178 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// parsePattern parses a pattern, which destructures a value in a let statement or a match arm. Patterns are
+// identifiers which bind the value, the wildcard _, literals and negative numbers which only match an equal value,
+// array patterns such as [a, [b, c], ...rest] and hash patterns such as {name, "years": age}. It returns nil if the
+// pattern is malformed
+func (p *Parser) parsePattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	case token.INT, token.FLOAT, token.STRING, token.TRUE, token.FALSE, token.NULL:
+		return p.parseLiteralPattern()
+	case token.MINUS:
+		if p.peekTokenIs(token.INT) || p.peekTokenIs(token.FLOAT) {
+			expression := &ast.PrefixExpression{Token: p.curToken, Operator: p.curToken.Literal}
+			p.nextToken()
+			expression.Right = p.parseLiteralPattern()
+			if expression.Right == nil {
+				return nil
+			}
+			return expression
+		}
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	}
+
+	p.errorAt(p.curToken, "expected a pattern, got %s instead", p.curToken.Type)
+	p.addHint("patterns are identifiers, _, literals, and array and hash patterns such as [first, ...rest] or {name, \"years\": age}")
+	return nil
+}
+
+// parseLiteralPattern parses the literal at the current token, returning nil if it is malformed
+func (p *Parser) parseLiteralPattern() ast.Expression {
+	literal := p.prefixParseFns[p.curToken.Type]()
+	if _, bad := literal.(*ast.BadExpression); bad {
+		return nil
+	}
+	return literal
+}
+
+func (p *Parser) parseArrayPattern() ast.Expression {
+	pattern := &ast.ArrayLiteral{Token: p.curToken, Elements: []ast.Expression{}}
+
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+
+		if p.curTokenIs(token.ELLIPSIS) {
+			spread := &ast.SpreadExpression{Token: p.curToken}
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			spread.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			pattern.Elements = append(pattern.Elements, spread)
+
+			if !p.peekTokenIs(token.RBRACKET) {
+				p.errorAt(spread.Token, "a rest pattern must be the last element and take the form ...<identifier>")
+				return nil
+			}
+			break
+		}
+
+		element := p.parsePattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+	pattern.Rbracket = p.curToken
+
+	return pattern
+}
+
+func (p *Parser) parseHashPattern() ast.Expression {
+	pattern := &ast.HashPattern{Token: p.curToken}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		var key ast.Expression
+		switch p.curToken.Type {
+		case token.IDENT:
+			key = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+		case token.STRING, token.INT, token.TRUE, token.FALSE:
+			key = p.parseLiteralPattern()
+			if key == nil {
+				return nil
+			}
+		default:
+			p.errorAt(p.curToken, "expected a key in hash pattern, got %s instead", p.curToken.Type)
+			p.addHint("hash pattern keys are identifiers, strings, integers or booleans e.g. {name, \"years\": age}")
+			return nil
+		}
+
+		var value ast.Expression
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			value = p.parsePattern()
+			if value == nil {
+				return nil
+			}
+		} else if p.curTokenIs(token.IDENT) {
+			value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		} else {
+			p.errorAt(p.peekToken, "expected next token to be :, got %s instead", p.peekToken.Type)
+			return nil
+		}
+
+		pattern.Keys = append(pattern.Keys, key)
+		pattern.Values = append(pattern.Values, value)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+
+	p.nextToken()
+	pattern.Rbrace = p.curToken
+
+	return pattern
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return &ast.BadStatement{Token: stmt.Token, Last: p.curToken}
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+		return &ast.BadStatement{Token: stmt.Token, Last: p.curToken}
+	}
+
+	p.loopDepth++
+	stmt.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseLoopControlStatement parses break or continue, which are only allowed inside the body of a loop
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.curToken
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	if p.loopDepth == 0 {
+		p.errorAt(tok, "%s outside of a loop", tok.Literal)
+		return &ast.BadStatement{Token: tok, Last: p.curToken}
+	}
+
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 	p.nextToken()
@@ -183,6 +615,18 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	var stmt = &ast.ExpressionStatement{Token: p.curToken}
 
@@ -199,7 +643,17 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
-		return nil
+		bad := p.badExpression(p.curToken)
+
+		// leave a closing token for the construct it closes, provided doing so can't stall the parser on it
+		if statementBoundaries[p.curToken.Type] || closingDelimiters[p.curToken.Type] ||
+			p.curTokenIs(token.COMMA) || p.curTokenIs(token.STRING_MIDDLE) {
+			if p.curToken.Pos.Offset > p.stmtStart.Pos.Offset {
+				p.backup()
+			}
+		}
+
+		return bad
 	}
 	leftExpression := prefix()
 
@@ -231,20 +685,42 @@ func (p *Parser) curPrecedence() int {
 	return LOWEST
 }
 
+// parseIllegal returns a placeholder for a token the lexer could not make sense of. The lexer has already reported the
+// problem, so no further diagnostic is recorded.
+func (p *Parser) parseIllegal() ast.Expression {
+	return p.badExpression(p.curToken)
+}
+
 func (p *Parser) parseIdentifier() ast.Expression {
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
+// parseIntegerLiteral parses an integer literal, which is a big integer literal if it does not fit in an int64
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	i, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
+	if errors.Is(err, strconv.ErrRange) {
+		if value, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+			return &ast.BigIntegerLiteral{Token: p.curToken, Value: value}
+		}
+	}
 	if err != nil {
-		p.errors = append(p.errors, "non integer literal received for INT token")
-		return nil
+		p.errorAt(p.curToken, "could not parse %q as integer", p.curToken.Literal)
+		return p.badExpression(p.curToken)
 	}
 
 	return &ast.IntegerLiteral{Token: p.curToken, Value: i}
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	f, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.errorAt(p.curToken, "could not parse %q as float", p.curToken.Literal)
+		return p.badExpression(p.curToken)
+	}
+
+	return &ast.FloatLiteral{Token: p.curToken, Value: f}
+}
+
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -267,6 +743,12 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 
 	// store the precendence of the left expression, use this to recursively parse the right
 	precedence := p.curPrecedence()
+
+	// ** is right associative, parsing the right with a lower precedence lets it absorb a following ** so that
+	// 2 ** 3 ** 2 is 2 ** (3 ** 2)
+	if p.curTokenIs(token.POWER) {
+		precedence--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 
@@ -277,19 +759,48 @@ func (p *Parser) parseBooleanExpression() ast.Expression {
 	return &ast.Boolean{Token: p.curToken, Value: p.curToken.Type == token.TRUE}
 }
 
+func (p *Parser) parseNull() ast.Expression {
+	return &ast.Null{Token: p.curToken}
+}
+
 func (p *Parser) parseStringExpression() ast.Expression {
 	exp := &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 
 	return exp
 }
 
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	exp := &ast.InterpolatedString{Token: p.curToken}
+	exp.Parts = []ast.Expression{&ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}}
+
+	for !p.curTokenIs(token.STRING_TAIL) {
+		p.nextToken()
+		exp.Parts = append(exp.Parts, p.parseExpression(LOWEST))
+
+		if !p.peekTokenIs(token.STRING_MIDDLE) && !p.peekTokenIs(token.STRING_TAIL) {
+			p.errorAt(p.peekToken, "expected } to close interpolated expression, got %s instead", p.peekToken.Type)
+			p.synchronize(token.STRING_TAIL)
+			return p.badExpression(exp.Token)
+		}
+
+		p.nextToken()
+		exp.Parts = append(exp.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+	}
+
+	exp.Tail = p.curToken
+
+	return exp
+}
+
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	start := p.curToken
 	p.nextToken()
 
 	exp := p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		p.synchronize(token.RPAREN)
+		return p.badExpression(start)
 	}
 
 	return exp
@@ -299,18 +810,18 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	expression := &ast.IfExpression{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return p.badExpression(expression.Token)
 	}
 
 	p.nextToken()
 	expression.Condition = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		return p.badExpression(expression.Token)
 	}
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return p.badExpression(expression.Token)
 	}
 
 	expression.Consequence = p.parseBlockStatement()
@@ -318,8 +829,26 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	if p.peekToken.Type == token.ELSE {
 		p.nextToken()
 
+		// else if is parsed as an else block containing only the nested if expression
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			ifToken := p.curToken
+
+			nested := p.parseIfExpression()
+			if _, ok := nested.(*ast.BadExpression); ok {
+				return p.badExpression(expression.Token)
+			}
+
+			expression.Alternative = &ast.BlockStatement{
+				Token:      ifToken,
+				Statements: []ast.Statement{&ast.ExpressionStatement{Token: ifToken, Value: nested}},
+				Rbrace:     p.curToken,
+			}
+			return expression
+		}
+
 		if !p.expectPeek(token.LBRACE) {
-			return nil
+			return p.badExpression(expression.Token)
 		}
 
 		expression.Alternative = p.parseBlockStatement()
@@ -328,6 +857,108 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LBRACE) {
+		return p.badExpression(expression.Token)
+	}
+	expression.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LPAREN) || !p.expectPeek(token.IDENT) {
+			p.addHint("a catch clause takes the form `catch (<identifier>) { <statements> }`")
+			return p.badExpression(expression.Token)
+		}
+		expression.Param = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+		if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+			return p.badExpression(expression.Token)
+		}
+		expression.Catch = p.parseBlockStatement()
+	}
+
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+
+		if !p.expectPeek(token.LBRACE) {
+			return p.badExpression(expression.Token)
+		}
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.errorAt(expression.Token, "try without catch or finally")
+		p.addHint("a try expression needs a `catch (<identifier>) { ... }` clause, a `finally { ... }` clause, or both")
+		return p.badExpression(expression.Token)
+	}
+
+	return expression
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return p.badExpression(expression.Token)
+	}
+
+	p.nextToken()
+	expression.Subject = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+		return p.badExpression(expression.Token)
+	}
+	nesting := p.nesting
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+
+		arm := &ast.MatchArm{Pattern: p.parsePattern()}
+		if arm.Pattern == nil {
+			p.skipMatchArms(nesting)
+			return p.badExpression(expression.Token)
+		}
+
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			p.nextToken()
+			arm.Guard = p.parseExpression(LOWEST)
+		}
+
+		if !p.expectPeek(token.ARROW) {
+			p.addHint("a match arm takes the form `<pattern> => <expression>` or `<pattern> if <guard> => <expression>`")
+			p.skipMatchArms(nesting)
+			return p.badExpression(expression.Token)
+		}
+
+		p.nextToken()
+		arm.Body = p.parseExpression(LOWEST)
+		expression.Arms = append(expression.Arms, arm)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			p.skipMatchArms(nesting)
+			return p.badExpression(expression.Token)
+		}
+	}
+
+	p.nextToken()
+	expression.Rbrace = p.curToken
+
+	return expression
+}
+
+// skipMatchArms skips to the closing brace of a malformed match expression, whose opening brace left nesting
+// delimiters open. Braces of the arms, which may already have been consumed, are told apart by how many delimiters
+// they leave open
+func (p *Parser) skipMatchArms(nesting int) {
+	for !p.curTokenIs(token.EOF) && !(p.curTokenIs(token.RBRACE) && p.nesting < nesting) {
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -342,62 +973,220 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		p.nextToken()
 	}
 
+	if p.curTokenIs(token.EOF) {
+		p.errorAt(p.curToken, "expected %s to close block, got %s instead", token.RBRACE, token.EOF)
+	}
+
+	block.Rbrace = p.curToken
+
 	return block
 }
 
+func (p *Parser) parseForInExpression() ast.Expression {
+	expression := &ast.ForInExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) || !p.expectPeek(token.IDENT) {
+		p.addHint("a for loop takes the form `for (<value> in <iterable>) { ... }` or `for (<key>, <value> in <iterable>) { ... }`")
+		return p.badExpression(expression.Token)
+	}
+
+	expression.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		if !p.expectPeek(token.IDENT) {
+			return p.badExpression(expression.Token)
+		}
+		expression.Key = expression.Value
+		expression.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.IN) {
+		p.addHint("a for loop takes the form `for (<value> in <iterable>) { ... }` or `for (<key>, <value> in <iterable>) { ... }`")
+		return p.badExpression(expression.Token)
+	}
+
+	p.nextToken()
+	expression.Iterable = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) || !p.expectPeek(token.LBRACE) {
+		return p.badExpression(expression.Token)
+	}
+
+	p.loopDepth++
+	expression.Body = p.parseBlockStatement()
+	p.loopDepth--
+
+	return expression
+}
+
 func (p *Parser) parseFunctionLiteral() ast.Expression {
 	lit := &ast.FunctionLiteral{Token: p.curToken}
 
 	if !p.expectPeek(token.LPAREN) {
-		return nil
+		return p.badExpression(lit.Token)
 	}
 
-	lit.Parameters = p.parseFunctionParameters()
+	if !p.parseFunctionParameters(lit) {
+		return p.badExpression(lit.Token)
+	}
 
 	if !p.expectPeek(token.LBRACE) {
-		return nil
+		return p.badExpression(lit.Token)
 	}
 
+	// a function body is not inside any loop the function literal is in, so break and continue cannot cross it
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+// parseFunctionParameters parses a comma separated list of parameters up to the closing ')' into lit, reporting
+// whether it succeeded. Each parameter may have a default value, and once a parameter has a default value every
+// parameter after it must have one too. The list may end with a rest parameter e.g. fn(a, b = 1, ...rest)
+func (p *Parser) parseFunctionParameters(lit *ast.FunctionLiteral) bool {
+	lit.Parameters = []*ast.Identifier{}
+	lit.Defaults = map[string]ast.Expression{}
 
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
-		return identifiers
+		return true
 	}
 
-	p.nextToken()
+	for {
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			if !p.expectPeek(token.IDENT) {
+				p.synchronize(token.RPAREN)
+				return false
+			}
+
+			lit.Rest = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			if !p.expectPeek(token.RPAREN) {
+				p.addHint("the rest parameter must be the last parameter")
+				p.synchronize(token.RPAREN)
+				return false
+			}
+			return true
+		}
 
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+		if !p.expectPeek(token.IDENT) {
+			p.synchronize(token.RPAREN)
+			return false
+		}
 
-	for p.peekTokenIs(token.COMMA) {
-		p.nextToken()
-		p.nextToken()
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		lit.Parameters = append(lit.Parameters, ident)
+
+		if p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			lit.Defaults[ident.Value] = p.parseExpression(LOWEST)
+		} else if len(lit.Defaults) > 0 {
+			p.errorAt(ident.Token, "parameter %s without a default value follows a parameter with a default value", ident.Value)
+			p.synchronize(token.RPAREN)
+			return false
+		}
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
 	}
 
 	if !p.expectPeek(token.RPAREN) {
-		return nil
+		p.synchronize(token.RPAREN)
+		return false
+	}
+
+	return true
+}
+
+// parseAssignExpression parses an assignment to an identifier or index expression. Assignment is right associative
+// so that a = b = 1 assigns 1 to both
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{Token: p.curToken, Target: target, Operator: p.curToken.Literal}
+
+	switch target := target.(type) {
+	case *ast.Identifier:
+	case *ast.IndexExpression:
+		if target.Optional {
+			p.errorAt(p.curToken, "cannot assign to %s", target.String())
+			p.addHint("an optional index ?.[ can only be read, use [ to assign")
+			return p.badExpression(p.curToken)
+		}
+	default:
+		p.errorAt(p.curToken, "cannot assign to %s", target.String())
+		p.addHint("only identifiers and index expressions such as `x` or `arr[0]` can be assigned to")
+		return p.badExpression(p.curToken)
+	}
+
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+
+	return expression
+}
+
+// parseConditionalExpression parses the branches of a conditional expression. The alternative extends as far right as
+// possible, so the operator is right associative and a ? b : c ? d : e is a ? b : (c ? d : e)
+func (p *Parser) parseConditionalExpression(condition ast.Expression) ast.Expression {
+	expression := &ast.ConditionalExpression{Token: p.curToken, Condition: condition}
+
+	p.nextToken()
+	expression.Consequence = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.COLON) {
+		p.addHint("a conditional expression takes the form `<condition> ? <expression> : <expression>`")
+		return p.badExpression(expression.Token)
 	}
 
-	return identifiers
+	p.nextToken()
+	expression.Alternative = p.parseExpression(LOWEST)
+
+	return expression
 }
 
 func (p *Parser) parseCallExpression(left ast.Expression) ast.Expression {
 	expression := &ast.CallExpression{Token: p.curToken}
 	expression.Function = left
 	expression.Arguments = p.parseExpressionList(token.RPAREN)
+	if expression.Arguments == nil {
+		return p.badExpression(expression.Token)
+	}
+
+	expression.Rparen = p.curToken
 
 	return expression
 }
 
+// parseListElement parses an element of an argument list or array literal, which may be spread e.g. ...xs
+func (p *Parser) parseListElement() ast.Expression {
+	if !p.curTokenIs(token.ELLIPSIS) {
+		return p.parseExpression(LOWEST)
+	}
+
+	spread := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	spread.Value = p.parseExpression(LOWEST)
+
+	return spread
+}
+
+// parseMisplacedSpread reports a spread outside of an argument list or array literal, parsing the spread expression
+// so that it is not reported again as a separate error
+func (p *Parser) parseMisplacedSpread() ast.Expression {
+	start := p.curToken
+	p.errorAt(start, "spread ... is only allowed in call arguments and array literals")
+
+	p.nextToken()
+	p.parseExpression(PREFIX)
+
+	return p.badExpression(start)
+}
+
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	args := []ast.Expression{}
 
@@ -407,15 +1196,16 @@ func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	}
 
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	args = append(args, p.parseListElement())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		args = append(args, p.parseListElement())
 	}
 
 	if !p.expectPeek(end) {
+		p.synchronize(end)
 		return nil
 	}
 
@@ -428,6 +1218,11 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	}
 
 	expression.Elements = p.parseExpressionList(token.RBRACKET)
+	if expression.Elements == nil {
+		return p.badExpression(expression.Token)
+	}
+
+	expression.Rbracket = p.curToken
 	return expression
 }
 
@@ -438,7 +1233,27 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	exp.Index = p.parseExpression(LOWEST)
 
 	if !p.expectPeek(token.RBRACKET) {
-		return nil
+		p.synchronize(token.RBRACKET)
+		return p.badExpression(exp.Token)
+	}
+
+	exp.Rbracket = p.curToken
+
+	return exp
+}
+
+// parseOptionalIndexExpression parses an optional index left?.[index]
+func (p *Parser) parseOptionalIndexExpression(left ast.Expression) ast.Expression {
+	start := p.curToken
+
+	if !p.expectPeek(token.LBRACKET) {
+		p.addHint("an optional index takes the form `<expression>?.[<index>]`")
+		return p.badExpression(start)
+	}
+
+	exp := p.parseIndexExpression(left)
+	if index, ok := exp.(*ast.IndexExpression); ok {
+		index.Optional = true
 	}
 
 	return exp
@@ -453,7 +1268,8 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		key := p.parseExpression(LOWEST)
 
 		if !p.expectPeek(token.COLON) {
-			return nil
+			p.synchronize(token.RBRACE)
+			return p.badExpression(hash.Token)
 		}
 
 		p.nextToken()
@@ -462,14 +1278,17 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		hash.Pairs[key] = value
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
-			return nil
+			p.synchronize(token.RBRACE)
+			return p.badExpression(hash.Token)
 		}
 	}
 
 	if !p.expectPeek(token.RBRACE) {
-		return nil
+		return p.badExpression(hash.Token)
 	}
 
+	hash.Rbrace = p.curToken
+
 	return hash
 }
 
@@ -3,7 +3,9 @@ package parser
 import (
 	"fmt"
 	"monkey-interpreter/ast"
+	"monkey-interpreter/diagnostic"
 	"monkey-interpreter/lexer"
+	"monkey-interpreter/token"
 	"testing"
 )
 
@@ -50,6 +52,60 @@ let foobar = 838383;
 	}
 }
 
+func TestDestructuringLetStatement(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, b] = xs;", "let [a, b] = xs;"},
+		{"let [first, ...rest] = f();", "let [first, ...rest] = f();"},
+		{"let [] = xs", "let [] = xs;"},
+		{"let {name, age} = person;", "let {name, age} = person;"},
+		{"let {\"full name\": name, years: age,} = person;", "let {full name: name, years: age} = person;"},
+		{"let [_, {point: [x, y]}] = pair;", "let [_, {point: [x, y]}] = pair;"},
+		{"let [1, -2.5, \"s\", true, null, x] = xs;", "let [1, (-2.5), s, true, null, x] = xs;"},
+		{"let {1: a, true: b, name: name} = h;", "let {1: a, true: b, name: name} = h;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testNoErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.LetStatement)
+		if !ok || stmt.Pattern == nil || stmt.Name != nil {
+			t.Fatalf("stmt is not a destructuring *ast.LetStatement. got=%#v", program.Statements[0])
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("program wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"let [a, +] = xs;", "1:9: error: expected a pattern, got + instead"},
+		{"let [...rest, a] = xs;", "1:6: error: a rest pattern must be the last element and take the form ...<identifier>"},
+		{"let [a b] = xs;", "1:8: error: expected next token to be ,, got IDENT instead"},
+		{"let {[a]: b} = h;", "1:6: error: expected a key in hash pattern, got [ instead"},
+		{"let {\"a\"} = h;", "1:9: error: expected next token to be :, got } instead"},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func testLetStatement(t *testing.T, stmt ast.Statement, name string) bool {
 	if stmt.TokenLiteral() != "let" {
 		t.Errorf("s.TokenLiteral not 'let'. got=%q", stmt.TokenLiteral())
@@ -186,6 +242,78 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		big      bool
+	}{
+		{"0xFF;", "255", false},
+		{"0o17;", "15", false},
+		{"0b1010;", "10", false},
+		{"1_000_000;", "1000000", false},
+		{"0x7FFF_FFFF_FFFF_FFFF;", "9223372036854775807", false},
+		// literals which do not fit in an int64 are big integer literals
+		{"9_223_372_036_854_775_808;", "9223372036854775808", true},
+		{"99999999999999999999;", "99999999999999999999", true},
+		{"0xFFFF_FFFF_FFFF_FFFF_FFFF;", "1208925819614629174706175", true},
+		{"0b1_0000000000000000000000000000000000000000000000000000000000000000;", "18446744073709551616", true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testNoErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		switch literal := stmt.Value.(type) {
+		case *ast.IntegerLiteral:
+			if tt.big {
+				t.Errorf("expected a big integer literal for %q. got=%T", tt.input, literal)
+			}
+		case *ast.BigIntegerLiteral:
+			if !tt.big {
+				t.Errorf("expected an integer literal for %q. got=%T", tt.input, literal)
+			}
+		default:
+			t.Fatalf("expression is not an integer literal. got=%T", stmt.Value)
+		}
+
+		if stmt.Value.String() != tt.expected {
+			t.Errorf("literal value not %s. got=%s", tt.expected, stmt.Value.String())
+		}
+	}
+}
+
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e3;", 1000},
+		{"2.5E-1;", 0.25},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testNoErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Value.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("expression is not *ast.FloatLiteral. got=%T", stmt.Value)
+		}
+
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"this is a long string"`
 
@@ -342,6 +470,28 @@ func TestOperatorPrecendence(t *testing.T) {
 			{"!(true == true)", "(!(true == true))"},
 			{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
 			{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])),(b[1]),(2 * ([1, 2][1])))"},
+			{"a || b && c", "(a || (b && c))"},
+			{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+			{"-2 ** 2", "(-(2 ** 2))"},
+			{"a * b ** c", "(a * (b ** c))"},
+			{"a % b * c", "((a % b) * c)"},
+			{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+			{"a & 1 == 0", "((a & 1) == 0)"},
+			{"1 << a + b", "(1 << (a + b))"},
+			{"a >> 1 | b << 2", "((a >> 1) | (b << 2))"},
+			{"~a & b", "((~a) & b)"},
+			{"a + 1 <= b * 2 == c >= d", "(((a + 1) <= (b * 2)) == (c >= d))"},
+			{"a && b || c && d", "((a && b) || (c && d))"},
+			{"a < b && c == d", "((a < b) && (c == d))"},
+			{"!a && b", "((!a) && b)"},
+			{"a < b ? a : b", "((a < b) ? a : b)"},
+			{"a || b ? c + 1 : d * 2", "((a || b) ? (c + 1) : (d * 2))"},
+			{"a ? b : c ? d : e", "(a ? b : (c ? d : e))"},
+			{"a ? b ? c : d : e", "(a ? (b ? c : d) : e)"},
+			{"x = a ? b : c", "(x = (a ? b : c))"},
+			{"f(a ? b : c, d)", "f((a ? b : c),d)"},
+			{"a ? x = 1 : x = 2", "(a ? (x = 1) : (x = 2))"},
+			{"{a ? b : c: d ? e : f}", "{(a ? b : c):(d ? e : f)}"},
 		}
 
 	for _, tt := range tests {
@@ -442,6 +592,41 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (x < y) { x } else if (x > y) { y } else { 0 }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	testNoErrors(t, p)
+
+	exp, ok := program.Statements[0].(*ast.ExpressionStatement).Value.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.IfExpression, got =%T", program.Statements[0])
+	}
+
+	if exp.Alternative == nil || len(exp.Alternative.Statements) != 1 {
+		t.Fatalf("alternative is not 1 statement. got=%v", exp.Alternative)
+	}
+
+	nested, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement).Value.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("alternative is not ast.IfExpression, got =%T", exp.Alternative.Statements[0])
+	}
+
+	if !testInfixExpression(t, nested.Condition, "x", ">", "y") {
+		return
+	}
+
+	if nested.Alternative == nil || len(nested.Alternative.Statements) != 1 {
+		t.Errorf("nested alternative is not 1 statement. got=%v", nested.Alternative)
+	}
+
+	if exp.End() != nested.End() {
+		t.Errorf("if expression ends at %s, expected %s", exp.End(), nested.End())
+	}
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y;}`
 	l := lexer.New(input)
@@ -489,6 +674,7 @@ func TestFunctionParameterParsing(t *testing.T) {
 		{input: "fn() {};", expectedParams: []string{}},
 		{input: "fn(x){};", expectedParams: []string{"x"}},
 		{input: "fn(x, y, z){};", expectedParams: []string{"x", "y", "z"}},
+		{input: "fn(x, y = 10){};", expectedParams: []string{"x", "y"}},
 	}
 
 	for _, test := range tests {
@@ -516,6 +702,336 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestFunctionDefaultParameters(t *testing.T) {
+	input := "let f = fn(a, b = 10, c = a + 1) { a };"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	testNoErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.LetStatement)
+	fl, ok := stmt.Value.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Value is not ast.FunctionLiteral. got=%T", stmt.Value)
+	}
+
+	if fl.Name != "f" {
+		t.Errorf("function name wrong. expected=%q, got=%q", "f", fl.Name)
+	}
+
+	if _, ok := fl.Defaults["a"]; ok || len(fl.Defaults) != 2 {
+		t.Fatalf("wrong defaults. got=%v", fl.Defaults)
+	}
+
+	testIntegerLiteral(t, fl.Defaults["b"], int64(10))
+	testInfixExpression(t, fl.Defaults["c"], "a", "+", 1)
+
+	if fl.String() != "fn(a, b = 10, c = (a + 1)){ a }" {
+		t.Errorf("function string wrong. got=%q", fl.String())
+	}
+
+	l = lexer.New("fn(a = 1, b) { a }")
+	p = New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	expected := "1:11: error: parameter b without a default value follows a parameter with a default value"
+	if len(errors) != 1 || errors[0] != expected {
+		t.Errorf("wrong errors. expected=%q, got=%v", expected, errors)
+	}
+}
+
+func TestRestParametersAndSpread(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(a, ...rest) { rest }", "fn(a, ...rest){ rest }"},
+		{"fn(...args) { args }", "fn(...args){ args }"},
+		{"fn(a, b = 1, ...rest) { a }", "fn(a, b = 1, ...rest){ a }"},
+		{"f(...xs)", "f(...xs)"},
+		{"f(1, ...xs, 2)", "f(1,...xs,2)"},
+		{"[...a, ...b]", "[...a, ...b]"},
+		{"[...f(x) + [1]]", "[...(f(x) + [1])]"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testNoErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn(...rest, a) { a }", "1:11: error: expected next token to be ), got , instead"},
+		{"let x = ...xs;", "1:9: error: spread ... is only allowed in call arguments and array literals"},
+		{"x..y", `1:2: error: illegal character "."`},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { let x = x + 1; if (x == 5) { continue; } break; }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	testNoErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+
+	if len(stmt.Body.Statements) != 3 {
+		t.Fatalf("body does not contain 3 statements. got=%d", len(stmt.Body.Statements))
+	}
+
+	if _, ok := stmt.Body.Statements[2].(*ast.BreakStatement); !ok {
+		t.Errorf("last statement is not *ast.BreakStatement. got=%T", stmt.Body.Statements[2])
+	}
+
+	if program.String() != "while(x < 10) let x = (x + 1);if(x == 5) continue;break;" {
+		t.Errorf("program wrong. got=%q", program.String())
+	}
+}
+
+func TestForInExpression(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedKey string
+		expected    string
+	}{
+		{"for (x in xs) { puts(x); }", "", "for(x in xs) puts(x)"},
+		{"for (k, v in {\"a\": 1}) { break; }", "k", "for(k, v in {a:1}) break;"},
+		{"for (c in \"abc\") { continue }", "", "for(c in abc) continue;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testNoErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		forIn, ok := stmt.Value.(*ast.ForInExpression)
+		if !ok {
+			t.Fatalf("expression is not *ast.ForInExpression. got=%T", stmt.Value)
+		}
+
+		if tt.expectedKey == "" && forIn.Key != nil {
+			t.Errorf("expected no key for %q. got=%s", tt.input, forIn.Key)
+		}
+
+		if tt.expectedKey != "" && (forIn.Key == nil || forIn.Key.Value != tt.expectedKey) {
+			t.Errorf("wrong key for %q. expected=%s, got=%v", tt.input, tt.expectedKey, forIn.Key)
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("program wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	l := lexer.New("for (x of xs) { x }")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 || errors[0] != "1:8: error: expected next token to be IN, got IDENT instead" {
+		t.Errorf("wrong errors. got=%v", errors)
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: error: break outside of a loop"},
+		{"if (true) { continue }", "1:13: error: continue outside of a loop"},
+		{"while (true) { let f = fn() { break; }; }", "1:31: error: break outside of a loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { 1 => \"one\", _ => \"many\" }", "match(x) { 1 => one, _ => many }"},
+		{"match (x) { -2.5 => 1, true => 2, }", "match(x) { (-2.5) => 1, true => 2 }"},
+		{"match (xs) { [] => 0, [a, ...rest] if a > 0 => a }", "match(xs) { [] => 0, [a, ...rest] if (a > 0) => a }"},
+		{"match (h) { {\"k\": [v, _]} => v }", "match(h) { {k: [v, _]} => v }"},
+		{"match (h) { {name, age: [a, ...rest]} => name }", "match(h) { {name, age: [a, ...rest]} => name }"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testNoErrors(t, p)
+
+		if _, ok := program.Statements[0].(*ast.ExpressionStatement).Value.(*ast.MatchExpression); !ok {
+			t.Fatalf("expression is not *ast.MatchExpression. got=%T", program.Statements[0])
+		}
+
+		if program.String() != tt.expected {
+			t.Errorf("program wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"match (x) { a + 1 => 2 }", "1:15: error: expected next token to be =>, got + instead"},
+		{"match (x) { f(1) => 2 }", "1:14: error: expected next token to be =>, got ( instead"},
+		{"match (x) { [...r, a] => 2 }", "1:14: error: a rest pattern must be the last element and take the form ...<identifier>"},
+		{"match (x) { {[k]: 1} => 2 }", "1:14: error: expected a key in hash pattern, got [ instead"},
+		{"match (x) { 1 2 }", "1:15: error: expected next token to be =>, got INT instead"},
+		{"match (x) { 1 => 2 3 => 4 }", "1:20: error: expected next token to be ,, got INT instead"},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { f() } catch (e) { e }", "try f() catch(e) e"},
+		{"try { f() } finally { g() }", "try f() finally g()"},
+		{"try { f() } catch (err) { 0 } finally { g() }", "try f() catch(err) 0 finally g()"},
+		{"throw error(\"bad\", 1);", "throw error(bad,1);"},
+		{"if (x) { throw x } else { 1 }", "ifx throw x;else 1"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testNoErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { f() }", "1:1: error: try without catch or finally"},
+		{"try { f() } catch { 1 }", "1:19: error: expected next token to be (, got { instead"},
+		{"try { f() } catch (1) { 1 }", "1:20: error: expected next token to be IDENT, got INT instead"},
+		{"try f()", "1:5: error: expected next token to be {, got IDENT instead"},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 5", "(x = 5)"},
+		{"x = y = 1 + 2", "(x = (y = (1 + 2)))"},
+		{"x += 1", "(x += 1)"},
+		{"arr[0] -= 2 * 3", "((arr[0]) -= (2 * 3))"},
+		{"h[\"k\"] *= 2", "((h[k]) *= 2)"},
+		{"x /= a || b", "(x /= (a || b))"},
+		{"a[0][1] = 5", "(((a[0])[1]) = 5)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testNoErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 = 2", "1:3: error: cannot assign to 1"},
+		{"f() += 1", "1:5: error: cannot assign to f()"},
+		{"a + b = c", "1:7: error: cannot assign to (a + b)"},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestCallExpression(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5)"
 	l := lexer.New(input)
@@ -746,6 +1262,51 @@ func TestParsingIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestParsingOptionalIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"config?.[\"db\"]", "(config?.[db])"},
+		{"config?.[\"db\"]?.[\"port\"] ?? 5432", "(((config?.[db])?.[port]) ?? 5432)"},
+		{"a ?? b ?? c", "((a ?? b) ?? c)"},
+		{"a ?? b || c", "(a ?? (b || c))"},
+		{"x = a ?? b", "(x = (a ?? b))"},
+		{"a ?? b ? c : d", "((a ?? b) ? c : d)"},
+		{"x == null", "(x == null)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testNoErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("program wrong. expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"a?.b", "1:4: error: expected next token to be [, got IDENT instead"},
+		{"a?.[0] = 1", "1:8: error: cannot assign to (a?.[0])"},
+	}
+
+	for _, tt := range errorTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != tt.expected {
+			t.Errorf("wrong errors for %q. expected=%q, got=%v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
 func TestParsingHashLiteralsStringKeys(t *testing.T) {
 	input := `{"one": 1, "two": 2, "three": 3}`
 	l := lexer.New(input)
@@ -877,3 +1438,274 @@ func TestParsingHashLiteralsWithIntegerKey(t *testing.T) {
 		testFunc(value)
 	}
 }
+
+func TestNodePositions(t *testing.T) {
+	input := `let x = add(1, 2);
+if (x) { [x][0] }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	testNoErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+
+	letStmt := program.Statements[0].(*ast.LetStatement)
+	ifExp := program.Statements[1].(*ast.ExpressionStatement).Value.(*ast.IfExpression)
+	indexExp := ifExp.Consequence.Statements[0].(*ast.ExpressionStatement).Value
+
+	tests := []struct {
+		node          ast.Node
+		expectedStart string
+		expectedEnd   string
+	}{
+		{program, "1:1", "2:18"},
+		{letStmt, "1:1", "1:18"},
+		{letStmt.Name, "1:5", "1:6"},
+		{letStmt.Value, "1:9", "1:18"},
+		{ifExp, "2:1", "2:18"},
+		{ifExp.Condition, "2:5", "2:6"},
+		{ifExp.Consequence, "2:8", "2:18"},
+		{indexExp, "2:10", "2:16"},
+	}
+
+	for i, tt := range tests {
+		if tt.node.Pos().String() != tt.expectedStart {
+			t.Errorf("tests[%d] - %T start wrong. expected=%s, got=%s", i, tt.node, tt.expectedStart, tt.node.Pos())
+		}
+
+		if tt.node.End().String() != tt.expectedEnd {
+			t.Errorf("tests[%d] - %T end wrong. expected=%s, got=%s", i, tt.node, tt.expectedEnd, tt.node.End())
+		}
+	}
+}
+
+func TestParserDiagnostics(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedMessage  string
+		expectedPos      string
+		expectedExpected token.TokenType
+		expectedFound    token.TokenType
+	}{
+		{"let x 5;", "expected next token to be =, got INT instead", "1:7", token.ASSIGN, token.INT},
+		{"let = 5;", "expected next token to be IDENT, got = instead", "1:5", token.IDENT, token.ASSIGN},
+		{"if (x { 1 }", "expected next token to be ), got { instead", "1:7", token.RPAREN, token.LBRACE},
+		{"\n  ;", "no prefix parse function for ; found.", "2:3", "", token.SEMICOLON},
+		{"a ? b;", "expected next token to be :, got ; instead", "1:6", token.COLON, token.SEMICOLON},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) == 0 {
+			t.Errorf("expected diagnostics for %q, got none", tt.input)
+			continue
+		}
+
+		d := diagnostics[0]
+		if d.Severity != diagnostic.Error {
+			t.Errorf("severity wrong for %q. got=%s", tt.input, d.Severity)
+		}
+
+		if d.Message != tt.expectedMessage {
+			t.Errorf("message wrong for %q. expected=%q, got=%q", tt.input, tt.expectedMessage, d.Message)
+		}
+
+		if d.Pos.String() != tt.expectedPos {
+			t.Errorf("position wrong for %q. expected=%s, got=%s", tt.input, tt.expectedPos, d.Pos)
+		}
+
+		if tt.expectedExpected != "" && (len(d.Expected) != 1 || d.Expected[0] != tt.expectedExpected) {
+			t.Errorf("expected tokens wrong for %q. expected=%v, got=%v", tt.input, tt.expectedExpected, d.Expected)
+		}
+
+		if d.Found.Type != tt.expectedFound {
+			t.Errorf("found token wrong for %q. expected=%s, got=%s", tt.input, tt.expectedFound, d.Found.Type)
+		}
+
+		if p.Errors()[0] != tt.expectedPos+": error: "+tt.expectedMessage {
+			t.Errorf("formatted error wrong for %q. got=%q", tt.input, p.Errors()[0])
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input              string
+		expectedErrors     int
+		expectedStatements []string
+	}{
+		{"let x 5; let y = 2;", 1, []string{"<bad statement>", "let y = 2;"}},
+		{"let = 5; y", 1, []string{"<bad statement>", "y"}},
+		{"let x = ; let y = 2;", 1, []string{"let x = <bad expression>;", "let y = 2;"}},
+		{"5 +; 6", 1, []string{"(5 + <bad expression>)", "6"}},
+		{"if (x { 1 } else { 2 }; let y = 2;", 1, []string{"<bad expression>", "let y = 2;"}},
+		{`let h = {"a" 1, "b": 2}; h`, 1, []string{"let h = <bad expression>;", "h"}},
+		{`let h = {"a": }; h`, 1, []string{"let h = {a:<bad expression>};", "h"}},
+		{"[1, ]; 2", 1, []string{"[1, <bad expression>]", "2"}},
+		{"add(1 2); 3", 1, []string{"<bad expression>", "3"}},
+		{"fn(x 1) { x }; 3", 1, []string{"<bad expression>", "3"}},
+		{"let f = fn() { let x 5; x }; f()", 1, []string{"let f = fn(){ <bad statement>x };", "f()"}},
+		{"(1 2); 3", 1, []string{"<bad expression>", "3"}},
+		{"); 1", 1, []string{"<bad expression>", "1"}},
+		{"fn() { 1", 1, []string{"fn(){ 1 }"}},
+		{"match (x) { {1} => 1, _ => 2 }; let y = 1;", 1, []string{"<bad expression>", "let y = 1;"}},
+		{"match (x) { [1, {\"a\": 1} 2] => 1 }; let y = 1;", 1, []string{"<bad expression>", "let y = 1;"}},
+		{"match (x) { {\"a\": 1} 2, _ => 3 }; let y = 1;", 1, []string{"<bad expression>", "let y = 1;"}},
+		{"match (x) { 1 => }; let y = 1;", 1, []string{"match(x) { 1 => <bad expression> }", "let y = 1;"}},
+		{"match (x) { 1 => {\"a\": 1} 2 }; let y = 1;", 1, []string{"<bad expression>", "let y = 1;"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+
+		if len(p.Diagnostics()) != tt.expectedErrors {
+			t.Errorf("wrong number of errors for %q. expected=%d, got=%d %v", tt.input, tt.expectedErrors, len(p.Diagnostics()), p.Errors())
+		}
+
+		if len(program.Statements) != len(tt.expectedStatements) {
+			t.Errorf("wrong number of statements for %q. expected=%d, got=%d", tt.input, len(tt.expectedStatements), len(program.Statements))
+			continue
+		}
+
+		for i, stmt := range program.Statements {
+			if stmt.String() != tt.expectedStatements[i] {
+				t.Errorf("statement %d wrong for %q. expected=%q, got=%q", i, tt.input, tt.expectedStatements[i], stmt.String())
+			}
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// add two numbers
+let add = fn(x, y) {
+	x + y /* the sum */
+};`
+
+	l := lexer.New(input, lexer.WithComments())
+	p := New(l)
+	program := p.ParseProgram()
+	testNoErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+	}
+
+	expected := []struct {
+		text string
+		pos  string
+	}{
+		{"// add two numbers", "1:1"},
+		{"/* the sum */", "3:8"},
+	}
+
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("program.Comments has wrong length. expected=%d, got=%d", len(expected), len(program.Comments))
+	}
+
+	for i, c := range program.Comments {
+		if c.String() != expected[i].text {
+			t.Errorf("comment %d wrong. expected=%q, got=%q", i, expected[i].text, c.String())
+		}
+
+		if c.Pos().String() != expected[i].pos {
+			t.Errorf("comment %d position wrong. expected=%s, got=%s", i, expected[i].pos, c.Pos())
+		}
+	}
+}
+
+func TestLexerDiagnostics(t *testing.T) {
+	input := `let a = "bad \q"; let b = 2; @; b`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	expected := []string{
+		`1:14: error: invalid escape sequence \q`,
+		`1:30: error: illegal character "@"`,
+	}
+
+	errors := p.Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. expected=%d, got=%d %v", len(expected), len(errors), errors)
+	}
+
+	for i, msg := range errors {
+		if msg != expected[i] {
+			t.Errorf("error %d wrong. expected=%q, got=%q", i, expected[i], msg)
+		}
+	}
+
+	if program.String() != "let a = <bad expression>;let b = 2;<bad expression>b" {
+		t.Errorf("program wrong. got=%q", program.String())
+	}
+}
+
+func TestInterpolatedStringParsing(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedString string
+		expectedParts  int
+	}{
+		{`"total: ${a + b}"`, "total: ${(a + b)}", 3},
+		{`"${a}${b}"`, "${a}${b}", 5},
+		{`"x ${f(1, "y ${z}")} w"`, `x ${f(1,y ${z})} w`, 3},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		testNoErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Value.(*ast.InterpolatedString)
+		if !ok {
+			t.Fatalf("exp not *ast.InterpolatedString. got=%T", stmt.Value)
+		}
+
+		if len(exp.Parts) != tt.expectedParts {
+			t.Errorf("wrong number of parts for %q. expected=%d, got=%d", tt.input, tt.expectedParts, len(exp.Parts))
+		}
+
+		if exp.String() != tt.expectedString {
+			t.Errorf("string wrong. expected=%q, got=%q", tt.expectedString, exp.String())
+		}
+	}
+}
+
+func TestInterpolatedStringErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{`"a ${b c} d"; 1`, "expected } to close interpolated expression, got IDENT instead"},
+		{`"a ${} d"; 1`, "no prefix parse function for STRING_TAIL found."},
+		{`"a ${b`, "expected } to close interpolated expression, got EOF instead"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Errorf("expected 1 diagnostic for %q, got %d %v", tt.input, len(diagnostics), p.Errors())
+			continue
+		}
+
+		if diagnostics[0].Message != tt.expectedMessage {
+			t.Errorf("message wrong for %q. expected=%q, got=%q", tt.input, tt.expectedMessage, diagnostics[0].Message)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"monkey-interpreter/diagnostic"
 	"monkey-interpreter/evaluator"
 	"monkey-interpreter/lexer"
 	"monkey-interpreter/object"
@@ -17,6 +18,10 @@ func Start(in io.Reader, out io.Writer) {
 	scanner := bufio.NewScanner(in)
 	env := object.NewEnvironment()
 
+	// every line is lexed with its own filename, so that a runtime error raised by code entered on an earlier line
+	// can be shown with the line it came from
+	history := map[string]string{}
+
 	for {
 		fmt.Fprintf(out, PROMPT)
 		scanned := scanner.Scan()
@@ -25,16 +30,23 @@ func Start(in io.Reader, out io.Writer) {
 		}
 
 		line := scanner.Text()
-		l := lexer.New(line)
+		filename := fmt.Sprintf("input[%d]", len(history)+1)
+		history[filename] = line
+
+		l := lexer.New(line, lexer.WithFilename(filename))
 		p := parser.New(l)
 
 		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if len(p.Diagnostics()) != 0 {
+			printParserErrors(out, line, p.Diagnostics())
 			continue
 		}
 
 		evaluated := evaluator.Eval(program, env)
+		if errObj, ok := evaluated.(*object.Error); ok && errObj.Pos.IsValid() {
+			printRuntimeError(out, history[errObj.Pos.Filename], errObj)
+			continue
+		}
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
@@ -55,11 +67,20 @@ const MONKEY_FACE = `            __,__
            '-----'
 `
 
-func printParserErrors(out io.Writer, errors []string) {
+func printParserErrors(out io.Writer, source string, diagnostics []diagnostic.Diagnostic) {
 	io.WriteString(out, MONKEY_FACE)
 	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, d := range diagnostics {
+		diagnostic.Render(out, source, d)
 	}
 }
+
+func printRuntimeError(out io.Writer, source string, err *object.Error) {
+	diagnostic.Render(out, source, diagnostic.Diagnostic{
+		Severity: diagnostic.Error,
+		Message:  err.Message,
+		Pos:      err.Pos,
+		End:      err.End,
+	})
+}
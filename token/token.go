@@ -2,7 +2,7 @@
 package token
 
 import (
-	"strconv"
+	"fmt"
 )
 
 type TokenType string
@@ -10,60 +10,91 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // position of the first character of the token
+	End     Position // position immediately after the last character of the token
 }
 
-var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-}
-
-var delimiters = map[string]TokenType{
-	",": COMMA,
-	";": SEMICOLON,
-	"(": LPAREN,
-	")": RPAREN,
-	"{": LBRACE,
-	"}": RBRACE,
-	"[": LBRACKET,
-	"]": RBRACKET,
+// Position describes a location in monkey source code.
+type Position struct {
+	Filename string // name of the source file, empty if the source has no file
+	Offset   int    // byte offset, starting at 0
+	Line     int    // line number, starting at 1
+	Column   int    // column number, starting at 1
 }
 
-func New(tokenType TokenType, ch byte) Token {
-	return Token{Type: tokenType, Literal: string(ch)}
+// IsValid reports whether the position has been set.
+func (p Position) IsValid() bool {
+	return p.Line > 0
 }
 
-func FindTokenType(literal string) Token {
-	if _, err := strconv.Atoi(literal); err == nil {
-		return Token{Type: INT, Literal: literal}
+// String returns the position in the form file:line:column, omitting parts which are unknown.
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
 	}
 
-	if tok, ok := keywords[literal]; ok {
-		return Token{Type: tok, Literal: literal}
+	if s == "" {
+		s = "-"
 	}
 
-	return Token{Type: IDENT, Literal: literal}
+	return s
+}
+
+var keywords = map[string]TokenType{
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"for":      FOR,
+	"in":       IN,
+	"match":    MATCH,
+	"null":     NULL,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
+}
+
+func New(tokenType TokenType, ch rune) Token {
+	return Token{Type: tokenType, Literal: string(ch)}
 }
 
-func IsDelimiter(literal byte) bool {
-	_, ok := delimiters[string(literal)]
+// LookupIdent returns the keyword token type for ident, or IDENT if ident is not a keyword.
+func LookupIdent(ident string) TokenType {
+	if tok, ok := keywords[ident]; ok {
+		return tok
+	}
 
-	return ok
+	return IDENT
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT" // A // line comment or /* block comment */
 
 	// Identifiers and literals
 	IDENT  = "IDENT" // An identifier e.g. add, foobar, x, y, ...
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 
+	// Interpolated strings e.g. "a ${b} c ${d} e" are lexed as STRING_HEAD "a ", the tokens of b, STRING_MIDDLE " c ",
+	// the tokens of d and then STRING_TAIL " e"
+	STRING_HEAD   = "STRING_HEAD"
+	STRING_MIDDLE = "STRING_MIDDLE"
+	STRING_TAIL   = "STRING_TAIL"
+
 	// Operators
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -71,10 +102,33 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
 	LT       = "<"
 	GT       = ">"
+	LT_EQ    = "<="
+	GT_EQ    = ">="
 	EQ       = "=="
 	NOT_EQ   = "!="
+	AND      = "&&"
+	OR       = "||"
+
+	AMPERSAND   = "&"
+	PIPE        = "|"
+	CARET       = "^"
+	TILDE       = "~"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
+	ELLIPSIS    = "..."
+	ARROW       = "=>"
+	QUESTION    = "?"
+	NULLISH     = "??"
+	OPTIONAL    = "?."
+
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
 
 	// Delimiters
 	COMMA     = ","
@@ -95,4 +149,15 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	FOR      = "FOR"
+	IN       = "IN"
+	MATCH    = "MATCH"
+	NULL     = "NULL"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	THROW    = "THROW"
 )